@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"log"
 	"os"
 	"strconv"
@@ -11,15 +13,25 @@ import (
 
 	dataloader "goflix/api-coordinator/internal/data"
 	"goflix/api-coordinator/internal/plattform"
+	"goflix/api-coordinator/internal/recommend"
 	"goflix/api-coordinator/internal/server/dispatcher"
 	httpserver "goflix/api-coordinator/internal/server/http"
 	tcpserver "goflix/api-coordinator/internal/server/tcp"
+	"goflix/pkg/types"
+
+	"github.com/redis/go-redis/v9"
 )
 
+// recommendAlgorithm identifica el cálculo que hace el pipeline; forma parte
+// de la clave de coalescing junto con la métrica de similitud, el usuario,
+// topN y la época del dataset.
+const recommendAlgorithm = "user-knn"
+
 type dispatchData struct {
 	userID      int
 	userRatings map[int]map[int]float64
 	userIDs     []int
+	neighborsK  int
 	topN        int
 }
 
@@ -30,7 +42,37 @@ func main() {
 	server := tcpserver.NewServer()
 
 	resultTimeout := parseDurationEnv("DISPATCHER_RESULT_TIMEOUT", 90*time.Second)
-	disp := dispatcher.New(server, resultTimeout)
+	chunkTimeout := parseDurationEnv("DISPATCHER_CHUNK_TIMEOUT", 10*time.Second)
+	numShards := parseIntEnv("DISPATCHER_SHARDS", 32)
+	neighborsK := parseIntEnv("DISPATCHER_NEIGHBORS_K", 30)
+	schedulerName := strings.TrimSpace(os.Getenv("DISPATCHER_SCHEDULER"))
+	if schedulerName == "" {
+		schedulerName = "consistent-hash"
+	}
+	scheduler, err := dispatcher.NewScheduler(schedulerName)
+	if err != nil {
+		log.Fatalf("[DISPATCHER] %v", err)
+	}
+	log.Printf("[DISPATCHER] Usando scheduler %s", scheduler.Name())
+	disp := dispatcher.New(server, chunkTimeout, numShards, scheduler)
+	scoring := types.Scoring{
+		Sim:          strings.TrimSpace(os.Getenv("DISPATCHER_SIMILARITY")),
+		Shrinkage:    parseFloatEnv("DISPATCHER_SHRINKAGE", 0),
+		MinOverlap:   parseNonNegativeIntEnv("DISPATCHER_MIN_OVERLAP", 3),
+		Significance: parseNonNegativeIntEnv("DISPATCHER_SIGNIFICANCE", 50),
+	}
+	if err := disp.SetScoring(scoring); err != nil {
+		log.Fatalf("[DISPATCHER] %v", err)
+	}
+	log.Printf("[DISPATCHER] Usando similitud %s", scoring)
+	if dir := strings.TrimSpace(os.Getenv("DISPATCHER_RECORD_DIR")); dir != "" {
+		recorder, err := dispatcher.NewRecorder(dir)
+		if err != nil {
+			log.Fatalf("[DISPATCHER] No se pudo crear el directorio de grabación: %v", err)
+		}
+		disp.SetRecorder(recorder)
+		log.Printf("[DISPATCHER] Grabando TASK/RESULT en %s", dir)
+	}
 
 	// datasetPath := datasetPathFromEnv()
 	// log.Printf("[SERVER] Leyendo dataset desde %s", datasetPath)
@@ -59,24 +101,66 @@ func main() {
 			log.Printf("[SERVER] Error cargando dataset: %v", err)
 			return
 		}
+		// los workers reciben cada shard una sola vez por época
+		disp.LoadDataset(userRatings, userIDs)
+
+		// el estado de los jobs de fondo se guarda en Redis para reanudarlos
+		// si el coordinador se reinicia
+		rdb := plattform.NewRedisClient()
+		disp.SetJobStore(dispatcher.NewRedisJobStore(rdb))
+
+		interval := parseDurationEnv("DISPATCHER_PRECOMPUTE_INTERVAL", 0)
+		go func() {
+			// primero se termina lo que quedó pendiente, así un precálculo
+			// reanudado no compite con uno nuevo
+			resumeJobs(ctx, disp, rdb, precomputeTTL(interval))
+			if interval > 0 {
+				batchSize := parseIntEnv("DISPATCHER_BATCH_SIZE", 32)
+				runPrecompute(ctx, disp, rdb, interval, neighborsK, batchSize)
+			}
+		}()
 
-		triggerDispatch := func(userID int, topN int) ([]dispatcher.Result, error) {
+		// pedidos idénticos concurrentes (mismo usuario, topN, dataset y
+		// algoritmo) comparten un único job distribuido y su resultado
+		inflight := dispatcher.NewCoalescer()
+		triggerDispatch := func(reqCtx context.Context, userID int, topN int, progress recommend.ProgressFunc) ([]types.Prediction, error) {
+			// sin deadline del cliente se usa DISPATCHER_RESULT_TIMEOUT
+			if _, ok := reqCtx.Deadline(); !ok {
+				var cancel context.CancelFunc
+				reqCtx, cancel = context.WithTimeout(reqCtx, resultTimeout)
+				defer cancel()
+			}
 			payload := dispatchData{
 				userID:      userID,
 				userRatings: userRatings,
 				userIDs:     userIDs,
+				neighborsK:  neighborsK,
 				topN:        topN,
 			}
-			return scheduleDatasetDispatch(ctx, disp, payload, &mu)
+
+			// el progreso de un job compartido solo llegaría a quien lo lanzó,
+			// así que los pedidos con progreso corren su propio job
+			if progress != nil {
+				return scheduleDatasetDispatch(reqCtx, disp, payload, &mu, progress)
+			}
+
+			key := fmt.Sprintf("%d:%d:%s:%s-%s", userID, topN, disp.Epoch(), recommendAlgorithm, disp.Scoring())
+			preds, shared, err := inflight.Do(reqCtx, key, func(jobCtx context.Context) ([]types.Prediction, error) {
+				return scheduleDatasetDispatch(jobCtx, disp, payload, &mu, nil)
+			})
+			if shared {
+				log.Printf("[DISPATCHER] Pedido de userID=%d resuelto por un job en curso", userID)
+			}
+			return preds, err
 		}
 
-		httpserver.NewRouter(ctx, triggerDispatch, server)
+		httpserver.NewRouter(ctx, triggerDispatch, disp, rdb, server)
 	}()
 
 	log.Fatal(server.Start(os.Getenv("WORKER_TCP_ADDR")))
 }
 
-func scheduleDatasetDispatch(ctx context.Context, disp *dispatcher.Dispatcher, data dispatchData, mu *sync.RWMutex) ([]dispatcher.Result, error) {
+func scheduleDatasetDispatch(ctx context.Context, disp *dispatcher.Dispatcher, data dispatchData, mu *sync.RWMutex, progress recommend.ProgressFunc) ([]types.Prediction, error) {
 	mu.RLock()
 	defer mu.RUnlock()
 	targetID := data.userID
@@ -91,22 +175,100 @@ func scheduleDatasetDispatch(ctx context.Context, disp *dispatcher.Dispatcher, d
 
 	log.Printf("[DISPATCHER] Despachando tarea automática para userID=%d con %d usuarios", targetID, len(data.userRatings))
 
-	resultsCh := make(chan dispatcher.Result, 100) // Buffer sufficiente para evitar bloqueo
-	count, err := disp.Run(ctx, targetID, data.userRatings, data.topN, resultsCh)
+	// pedidos HTTP: prioridad interactiva, repartida en forma justa entre usuarios
+	opts := dispatcher.JobOptions{
+		Priority: dispatcher.PriorityInteractive,
+		Tenant:   strconv.Itoa(targetID),
+	}
+
+	// vecinos más cercanos -> predicción de ratings para películas no vistas
+	if progress == nil {
+		return disp.Recommend(ctx, opts, targetID, data.neighborsK, data.topN)
+	}
+	return disp.RecommendProgress(ctx, opts, targetID, data.neighborsK, data.topN, func(p dispatcher.Progress) {
+		progress(recommend.Progress{
+			Done:        p.Done,
+			Total:       p.Total,
+			Block:       p.Block,
+			Partial:     p.Partial,
+			Predictions: p.Predictions,
+		})
+	})
+}
+
+const (
+	precomputeTopN      = 50
+	precomputeKeyPrefix = "recs:"
+	precomputeMinTTL    = 48 * time.Hour
+)
+
+// precomputeTTL mantiene las recomendaciones precalculadas hasta que la
+// siguiente pasada las reemplace.
+func precomputeTTL(interval time.Duration) time.Duration {
+	if ttl := 2 * interval; ttl > precomputeMinTTL {
+		return ttl
+	}
+	return precomputeMinTTL
+}
+
+// storeRecs guarda las predicciones de cada usuario en Redis (recs:<userID>)
+// y cuenta cuántos usuarios se guardaron.
+func storeRecs(ctx context.Context, rdb *redis.Client, ttl time.Duration, stored *int) dispatcher.BatchSink {
+	return func(userID int, preds []types.Prediction) {
+		data, err := json.Marshal(preds)
+		if err != nil {
+			return
+		}
+		if err := rdb.Set(ctx, precomputeKeyPrefix+strconv.Itoa(userID), data, ttl).Err(); err != nil {
+			log.Printf("[DISPATCHER] Error guardando precálculo de %d: %v", userID, err)
+			return
+		}
+		*stored++
+	}
+}
+
+// resumeJobs retoma los jobs de fondo que quedaron sin terminar cuando el
+// coordinador se detuvo, desde el último lote confirmado.
+func resumeJobs(ctx context.Context, disp *dispatcher.Dispatcher, rdb *redis.Client, ttl time.Duration) {
+	recs, err := disp.UnfinishedJobs(ctx)
 	if err != nil {
-		return nil, err
+		log.Printf("[DISPATCHER] Error leyendo jobs pendientes: %v", err)
+		return
 	}
+	for _, rec := range recs {
+		log.Printf("[DISPATCHER] Reanudando job %s (%s) desde el usuario %d de %d", rec.ID, rec.Kind, rec.Next, rec.Users)
+		stored := 0
+		if err := disp.ResumeJob(ctx, rec, storeRecs(ctx, rdb, ttl, &stored)); err != nil {
+			log.Printf("[DISPATCHER] Job %s interrumpido: %v", rec.ID, err)
+			continue
+		}
+		log.Printf("[DISPATCHER] Job %s terminado: %d usuarios", rec.ID, stored)
+	}
+}
+
+// runPrecompute recalcula periódicamente las recomendaciones de todos los
+// usuarios con jobs por lotes de prioridad background y las deja en Redis
+// (recs:<userID>) para consumo offline.
+func runPrecompute(ctx context.Context, disp *dispatcher.Dispatcher, rdb *redis.Client, interval time.Duration, neighborsK, batchSize int) {
+	ttl := precomputeTTL(interval)
+
+	for {
+		start := time.Now()
+		log.Printf("[DISPATCHER] Precálculo de recomendaciones iniciado")
+		stored := 0
+		err := disp.Precompute(ctx, neighborsK, precomputeTopN, batchSize, storeRecs(ctx, rdb, ttl, &stored))
+		if err != nil {
+			log.Printf("[DISPATCHER] Precálculo interrumpido: %v", err)
+		} else {
+			log.Printf("[DISPATCHER] Precálculo terminado: %d usuarios en %v", stored, time.Since(start))
+		}
 
-	var results []dispatcher.Result
-	for i := 0; i < count; i++ {
 		select {
-		case res := <-resultsCh:
-			results = append(results, res)
+		case <-time.After(interval):
 		case <-ctx.Done():
-			return results, ctx.Err()
+			return
 		}
 	}
-	return results, nil
 }
 
 func parseDurationEnv(key string, fallback time.Duration) time.Duration {
@@ -123,3 +285,41 @@ func parseDurationEnv(key string, fallback time.Duration) time.Duration {
 	log.Printf("[DISPATCHER] Valor inválido para %s: %s, usando %v", key, val, fallback)
 	return fallback
 }
+
+func parseIntEnv(key string, fallback int) int {
+	val := strings.TrimSpace(os.Getenv(key))
+	if val == "" {
+		return fallback
+	}
+	if n, err := strconv.Atoi(val); err == nil && n > 0 {
+		return n
+	}
+	log.Printf("[DISPATCHER] Valor inválido para %s: %s, usando %d", key, val, fallback)
+	return fallback
+}
+
+// parseNonNegativeIntEnv es como parseIntEnv pero acepta 0, para las
+// variables donde 0 desactiva el ajuste.
+func parseNonNegativeIntEnv(key string, fallback int) int {
+	val := strings.TrimSpace(os.Getenv(key))
+	if val == "" {
+		return fallback
+	}
+	if n, err := strconv.Atoi(val); err == nil && n >= 0 {
+		return n
+	}
+	log.Printf("[DISPATCHER] Valor inválido para %s: %s, usando %d", key, val, fallback)
+	return fallback
+}
+
+func parseFloatEnv(key string, fallback float64) float64 {
+	val := strings.TrimSpace(os.Getenv(key))
+	if val == "" {
+		return fallback
+	}
+	if f, err := strconv.ParseFloat(val, 64); err == nil && f >= 0 {
+		return f
+	}
+	log.Printf("[DISPATCHER] Valor inválido para %s: %s, usando %g", key, val, fallback)
+	return fallback
+}
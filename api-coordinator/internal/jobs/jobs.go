@@ -0,0 +1,96 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"goflix/api-coordinator/internal/server/dispatcher"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Lookup devuelve el estado persistido de un job de fondo; lo implementa
+// *dispatcher.Dispatcher.
+type Lookup interface {
+	JobStatus(ctx context.Context, id string) (*dispatcher.JobRecord, error)
+}
+
+type Handler struct {
+	jobs   Lookup
+	admins map[string]bool // user_id que pueden consultar cualquier job
+}
+
+func NewHandler(jobs Lookup, admins []string) *Handler {
+	h := &Handler{jobs: jobs, admins: make(map[string]bool, len(admins))}
+	for _, id := range admins {
+		h.admins[id] = true
+	}
+	return h
+}
+
+func (h *Handler) RegisterRoutes(g *gin.RouterGroup) {
+	g.GET("/:id", h.GetJob)
+}
+
+// Status es lo que se expone de un job: su avance, sin la lista de usuarios
+// que procesa (en un precálculo, todos los del dataset).
+type Status struct {
+	ID         string               `json:"id"`
+	Kind       string               `json:"kind"`
+	Priority   string               `json:"priority"`
+	Tenant     string               `json:"tenant"`
+	Status     dispatcher.JobStatus `json:"status"`
+	Error      string               `json:"error,omitempty"`
+	Users      int                  `json:"users"`
+	Next       int                  `json:"next"`
+	TasksTotal int                  `json:"tasks_total"`
+	TasksDone  int                  `json:"tasks_done"`
+	CreatedAt  time.Time            `json:"created_at"`
+	UpdatedAt  time.Time            `json:"updated_at"`
+}
+
+func newStatus(rec *dispatcher.JobRecord) Status {
+	return Status{
+		ID:         rec.ID,
+		Kind:       rec.Kind,
+		Priority:   rec.Priority.String(),
+		Tenant:     rec.Tenant,
+		Status:     rec.Status,
+		Error:      rec.Error,
+		Users:      rec.Users,
+		Next:       rec.Next,
+		TasksTotal: rec.TasksTotal,
+		TasksDone:  rec.TasksDone,
+		CreatedAt:  rec.CreatedAt,
+		UpdatedAt:  rec.UpdatedAt,
+	}
+}
+
+// GetJob devuelve el estado de un job de fondo (lote o precálculo) tal como
+// quedó guardado en el JobStore, así sigue consultable tras un reinicio. Solo
+// lo ven los administradores y el usuario dueño del job (su tenant); para el
+// resto responde 404, igual que si no existiera.
+func (h *Handler) GetJob(c *gin.Context) {
+	rec, err := h.jobs.JobStatus(c.Request.Context(), c.Param("id"))
+	switch {
+	case errors.Is(err, dispatcher.ErrJobNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "job no encontrado"})
+	case errors.Is(err, dispatcher.ErrNoJobStore):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error consultando el job"})
+	case !h.canView(c.GetString("user_id"), rec):
+		c.JSON(http.StatusNotFound, gin.H{"error": "job no encontrado"})
+	default:
+		c.JSON(http.StatusOK, newStatus(rec))
+	}
+}
+
+func (h *Handler) canView(userID string, rec *dispatcher.JobRecord) bool {
+	if userID == "" {
+		return false
+	}
+	return h.admins[userID] || rec.Tenant == userID
+}
@@ -0,0 +1,77 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"goflix/api-coordinator/internal/server/dispatcher"
+
+	"github.com/gin-gonic/gin"
+)
+
+type fakeLookup map[string]*dispatcher.JobRecord
+
+func (f fakeLookup) JobStatus(_ context.Context, id string) (*dispatcher.JobRecord, error) {
+	rec, ok := f[id]
+	if !ok {
+		return nil, dispatcher.ErrJobNotFound
+	}
+	return rec, nil
+}
+
+func TestGetJob(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	lookup := fakeLookup{
+		"pre": {ID: "pre", Kind: dispatcher.JobKindPrecompute, Tenant: "precompute", Users: 3, Status: dispatcher.JobRunning},
+		"own": {ID: "own", Kind: dispatcher.JobKindBatch, Tenant: "42", Users: 1, Status: dispatcher.JobDone},
+	}
+
+	tests := []struct {
+		name   string
+		userID string
+		id     string
+		want   int
+	}{
+		{"dueño", "42", "own", http.StatusOK},
+		{"otro usuario", "7", "own", http.StatusNotFound},
+		{"precálculo sin ser admin", "42", "pre", http.StatusNotFound},
+		{"admin", "1", "pre", http.StatusOK},
+		{"sin usuario", "", "own", http.StatusNotFound},
+		{"inexistente", "1", "nope", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			g := r.Group("/jobs", func(c *gin.Context) {
+				if tt.userID != "" {
+					c.Set("user_id", tt.userID)
+				}
+			})
+			NewHandler(lookup, []string{"1"}).RegisterRoutes(g)
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/jobs/"+tt.id, nil))
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			// la lista de usuarios nunca sale en la respuesta
+			if strings.Contains(w.Body.String(), "user_ids") {
+				t.Fatalf("la respuesta expone los usuarios: %s", w.Body)
+			}
+			var st Status
+			if err := json.Unmarshal(w.Body.Bytes(), &st); err != nil {
+				t.Fatal(err)
+			}
+			if st.ID != tt.id || st.Users != lookup[tt.id].Users {
+				t.Fatalf("Status = %+v", st)
+			}
+		})
+	}
+}
@@ -21,6 +21,7 @@ type WorkerStats struct {
 	State    types.WorkerState `json:"state"`
 	LastSeen time.Time         `json:"last_seen"`
 	IP       string            `json:"ip"`
+	types.WorkerLoad
 }
 
 type SystemStats struct {
@@ -79,10 +80,11 @@ func (s *monitoringService) GetStatus(ctx context.Context) MonitoringStatus {
 			ip = w.Conn.RemoteAddr().String()
 		}
 		workers = append(workers, WorkerStats{
-			ID:       id,
-			State:    w.State,
-			LastSeen: w.LastSeen,
-			IP:       ip,
+			ID:         id,
+			State:      w.State,
+			LastSeen:   w.LastSeen,
+			IP:         ip,
+			WorkerLoad: w.Load,
 		})
 	}
 	s.tcpServer.Mu.RUnlock()
@@ -1,6 +1,7 @@
 package recommend
 
 import (
+	"errors"
 	"net/http"
 	"sort"
 	"strconv"
@@ -22,6 +23,8 @@ func NewHandler(svc Service) *Handler {
 func (h *Handler) RegisterRoutes(g *gin.RouterGroup) {
 	g.POST("", h.Recommend)
 	g.GET("/popular", h.GetPopularMovies)
+	g.GET("/jobs/:id", h.GetJob)
+	g.GET("/stream", h.Stream)
 }
 
 func (h *Handler) GetPopularMovies(c *gin.Context) {
@@ -42,8 +45,10 @@ func (h *Handler) GetPopularMovies(c *gin.Context) {
 }
 
 type recommendRequest struct {
-	UserID int `json:"user_id" binding:"required"`
-	TopN   int `json:"top_n"`
+	UserID      int    `json:"user_id" binding:"required"`
+	TopN        int    `json:"top_n"`
+	Async       bool   `json:"async"`        // responder 202 con un job en vez de esperar
+	CallbackURL string `json:"callback_url"` // opcional, solo en modo async
 }
 
 func (h *Handler) Recommend(c *gin.Context) {
@@ -69,6 +74,24 @@ func (h *Handler) Recommend(c *gin.Context) {
 		req.TopN = 10
 	}
 
+	if req.Async || c.Query("async") == "true" {
+		job, err := h.svc.SubmitRecommendation(c.Request.Context(), userID, req.TopN, req.CallbackURL)
+		if errors.Is(err, ErrTooManyJobs) {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{
+			"job_id":     job.ID,
+			"status":     job.Status,
+			"status_url": "/api/recomend/jobs/" + job.ID,
+		})
+		return
+	}
+
 	recommendations, err := h.svc.GetRecommendationsWithDetails(c.Request.Context(), userID, req.TopN)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al calcular recomendaciones"})
@@ -82,3 +105,58 @@ func (h *Handler) Recommend(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"recommendations": recommendations})
 }
+
+// GetJob devuelve el estado de un pedido asíncrono y, si terminó, sus
+// recomendaciones. Solo el usuario que lo creó puede consultarlo.
+func (h *Handler) GetJob(c *gin.Context) {
+	job, ok := h.svc.GetJob(c.Request.Context(), c.Param("id"))
+	if !ok || strconv.Itoa(job.UserID) != c.GetString("user_id") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "job no encontrado"})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// Stream calcula las recomendaciones del usuario y las envía por SSE a
+// medida que los workers completan bloques:
+//   - block: un bloque terminado (done/total)
+//   - top:   el top-N combinado con los bloques recibidos hasta ahora
+//   - final: las recomendaciones finales con el detalle de cada película
+//   - error: el cálculo falló
+func (h *Handler) Stream(c *gin.Context) {
+	userID, err := strconv.Atoi(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id format"})
+		return
+	}
+
+	topN := 10
+	if nStr := c.Query("top_n"); nStr != "" {
+		if n, err := strconv.Atoi(nStr); err == nil && n > 0 {
+			topN = n
+		}
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // evita el buffering de nginx
+	emit := func(event string, data any) {
+		c.SSEvent(event, data)
+		c.Writer.Flush()
+	}
+
+	recommendations, err := h.svc.StreamRecommendations(c.Request.Context(), userID, topN, func(p Progress) {
+		emit("block", gin.H{"done": p.Done, "total": p.Total, "block_id": p.Block, "partial": p.Partial})
+		emit("top", gin.H{"done": p.Done, "total": p.Total, "predictions": p.Predictions})
+	})
+	if err != nil {
+		emit("error", gin.H{"error": "error al calcular recomendaciones"})
+		return
+	}
+
+	sort.Slice(recommendations, func(i, j int) bool {
+		return recommendations[i].Score > recommendations[j].Score
+	})
+	emit("final", gin.H{"recommendations": recommendations})
+}
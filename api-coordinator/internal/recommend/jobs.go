@@ -0,0 +1,409 @@
+package recommend
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// JobStatus es el estado de un pedido de recomendación asíncrono.
+type JobStatus string
+
+const (
+	JobPending JobStatus = "pending"
+	JobRunning JobStatus = "running"
+	JobDone    JobStatus = "done"
+	JobFailed  JobStatus = "failed"
+)
+
+const (
+	jobRetention     = time.Hour // tiempo que un job terminado sigue consultable
+	callbackAttempts = 3
+	callbackBackoff  = time.Second
+	callbackTimeout  = 10 * time.Second
+	jobStoreTimeout  = 2 * time.Second
+)
+
+var (
+	// ErrJobNotFound se devuelve al consultar un job que no existe o expiró.
+	ErrJobNotFound = errors.New("job no encontrado")
+	// ErrTooManyJobs se devuelve si el usuario ya tiene el máximo de pedidos
+	// asíncronos sin terminar.
+	ErrTooManyJobs = errors.New("demasiados pedidos asíncronos en curso")
+)
+
+// AsyncJob es un pedido de recomendaciones que se calcula en segundo plano.
+type AsyncJob struct {
+	ID              string             `json:"job_id"`
+	UserID          int                `json:"user_id"`
+	TopN            int                `json:"top_n"`
+	Status          JobStatus          `json:"status"`
+	Recommendations []RecommendedMovie `json:"recommendations,omitempty"`
+	Error           string             `json:"error,omitempty"`
+	CallbackURL     string             `json:"callback_url,omitempty"`
+	CreatedAt       time.Time          `json:"created_at"`
+	FinishedAt      *time.Time         `json:"finished_at,omitempty"`
+}
+
+// Finished indica si el job ya no va a avanzar.
+func (j *AsyncJob) Finished() bool {
+	return j.Status == JobDone || j.Status == JobFailed
+}
+
+// AsyncOptions configura los pedidos asíncronos.
+type AsyncOptions struct {
+	MaxJobsPerUser int // pedidos sin terminar por usuario (0 = sin límite)
+	// CallbackAllowlist son los hosts aceptados en callback_url ("example.com"
+	// o ".example.com" para sus subdominios). Vacía acepta cualquier host que
+	// resuelva solo a direcciones públicas.
+	CallbackAllowlist []string
+	// Store persiste los jobs para que sobrevivan a un reinicio; nil los
+	// guarda solo en memoria.
+	Store JobStore
+}
+
+// JobStore persiste los pedidos asíncronos.
+type JobStore interface {
+	Save(ctx context.Context, job *AsyncJob) error
+	Get(ctx context.Context, id string) (*AsyncJob, error)
+	// Unfinished devuelve los jobs pendientes o en curso, para reanudarlos.
+	Unfinished(ctx context.Context) ([]*AsyncJob, error)
+}
+
+const (
+	redisAsyncKeyPrefix = "async:"
+	redisActiveAsyncKey = "async:active"
+)
+
+// RedisJobStore guarda cada job como JSON en async:<id> y mantiene el índice
+// async:active con los que aún no terminaron.
+type RedisJobStore struct {
+	client *redis.Client
+}
+
+func NewRedisJobStore(client *redis.Client) *RedisJobStore {
+	return &RedisJobStore{client: client}
+}
+
+func (s *RedisJobStore) Save(ctx context.Context, job *AsyncJob) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	pipe := s.client.TxPipeline()
+	if job.Finished() {
+		pipe.Set(ctx, redisAsyncKeyPrefix+job.ID, data, jobRetention)
+		pipe.SRem(ctx, redisActiveAsyncKey, job.ID)
+	} else {
+		pipe.Set(ctx, redisAsyncKeyPrefix+job.ID, data, 0)
+		pipe.SAdd(ctx, redisActiveAsyncKey, job.ID)
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (s *RedisJobStore) Get(ctx context.Context, id string) (*AsyncJob, error) {
+	data, err := s.client.Get(ctx, redisAsyncKeyPrefix+id).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var job AsyncJob
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (s *RedisJobStore) Unfinished(ctx context.Context) ([]*AsyncJob, error) {
+	ids, err := s.client.SMembers(ctx, redisActiveAsyncKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	jobs := make([]*AsyncJob, 0, len(ids))
+	for _, id := range ids {
+		job, err := s.Get(ctx, id)
+		if errors.Is(err, ErrJobNotFound) {
+			s.client.SRem(ctx, redisActiveAsyncKey, id)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// jobRegistry guarda en memoria los jobs asíncronos en curso y los
+// terminados durante jobRetention, y los replica en el store si hay uno.
+type jobRegistry struct {
+	mu     sync.RWMutex
+	jobs   map[string]*AsyncJob
+	opts   AsyncOptions
+	client *http.Client
+}
+
+func newJobRegistry(opts AsyncOptions) *jobRegistry {
+	return &jobRegistry{
+		jobs:   make(map[string]*AsyncJob),
+		opts:   opts,
+		client: callbackClient(len(opts.CallbackAllowlist) == 0),
+	}
+}
+
+// callbackClient arma el cliente HTTP de los callbacks. Con checkIPs, el
+// dialer rechaza direcciones no públicas al conectar, lo que también cubre
+// redirecciones y un DNS que cambie entre la validación y el POST.
+func callbackClient(checkIPs bool) *http.Client {
+	if !checkIPs {
+		return &http.Client{Timeout: callbackTimeout}
+	}
+	dialer := &net.Dialer{
+		Timeout: callbackTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+				return fmt.Errorf("callback a dirección no pública %s", host)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil // el proxy ocultaría la dirección real
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: callbackTimeout, Transport: transport}
+}
+
+// cgnat es el rango compartido 100.64.0.0/10, que net.IP no clasifica.
+var cgnat = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// publicIP indica si ip es una dirección pública, a la que se puede hacer
+// un callback sin exponer servicios internos.
+func publicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || cgnat.Contains(ip))
+}
+
+// validateCallbackURL acepta solo URLs absolutas http o https hacia un host
+// de la allowlist o, sin allowlist, que resuelva solo a direcciones públicas.
+func (r *jobRegistry) validateCallbackURL(ctx context.Context, raw string) error {
+	if raw == "" {
+		return nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return fmt.Errorf("callback_url inválida: %q", raw)
+	}
+	host := strings.ToLower(u.Hostname())
+
+	if len(r.opts.CallbackAllowlist) > 0 {
+		for _, allowed := range r.opts.CallbackAllowlist {
+			if host == allowed || (strings.HasPrefix(allowed, ".") && strings.HasSuffix(host, allowed)) {
+				return nil
+			}
+		}
+		return fmt.Errorf("callback_url no permitida: %q", host)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, callbackTimeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("callback_url: no se pudo resolver %q", host)
+	}
+	for _, addr := range addrs {
+		if !publicIP(addr.IP) {
+			return fmt.Errorf("callback_url no permitida: %q resuelve a una dirección no pública", host)
+		}
+	}
+	return nil
+}
+
+// create registra un job nuevo si el usuario no alcanzó el máximo de
+// pedidos sin terminar.
+func (r *jobRegistry) create(userID, topN int, callbackURL string) (*AsyncJob, error) {
+	job := &AsyncJob{
+		ID:          uuid.New().String(),
+		UserID:      userID,
+		TopN:        topN,
+		Status:      JobPending,
+		CallbackURL: callbackURL,
+		CreatedAt:   time.Now(),
+	}
+
+	r.mu.Lock()
+	if max := r.opts.MaxJobsPerUser; max > 0 {
+		active := 0
+		for _, j := range r.jobs {
+			if j.UserID == userID && !j.Finished() {
+				active++
+			}
+		}
+		if active >= max {
+			r.mu.Unlock()
+			return nil, ErrTooManyJobs
+		}
+	}
+	r.jobs[job.ID] = job
+	snapshot := *job
+	r.mu.Unlock()
+
+	r.save(&snapshot)
+	return job, nil
+}
+
+// add registra en memoria un job leído del store.
+func (r *jobRegistry) add(job *AsyncJob) {
+	r.mu.Lock()
+	r.jobs[job.ID] = job
+	r.mu.Unlock()
+}
+
+// get devuelve una copia del job para no exponer el estado compartido. Si
+// no está en memoria (por ejemplo, tras un reinicio) se busca en el store.
+func (r *jobRegistry) get(ctx context.Context, id string) (AsyncJob, bool) {
+	r.mu.RLock()
+	job, ok := r.jobs[id]
+	var snapshot AsyncJob
+	if ok {
+		snapshot = *job
+	}
+	r.mu.RUnlock()
+	if ok || r.opts.Store == nil {
+		return snapshot, ok
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, jobStoreTimeout)
+	defer cancel()
+	stored, err := r.opts.Store.Get(ctx, id)
+	if err != nil {
+		if !errors.Is(err, ErrJobNotFound) {
+			log.Printf("[RECOMMEND] Error leyendo el job %s: %v", id, err)
+		}
+		return AsyncJob{}, false
+	}
+	return *stored, true
+}
+
+func (r *jobRegistry) setRunning(id string) {
+	r.mu.Lock()
+	job, ok := r.jobs[id]
+	var snapshot AsyncJob
+	if ok {
+		job.Status = JobRunning
+		snapshot = *job
+	}
+	r.mu.Unlock()
+	if ok {
+		r.save(&snapshot)
+	}
+}
+
+// finish guarda el resultado, notifica el callback y programa la limpieza.
+func (r *jobRegistry) finish(id string, recs []RecommendedMovie, err error) {
+	now := time.Now()
+	r.mu.Lock()
+	job, ok := r.jobs[id]
+	if !ok {
+		r.mu.Unlock()
+		return
+	}
+	if err != nil {
+		job.Status = JobFailed
+		job.Error = err.Error()
+	} else {
+		job.Status = JobDone
+		job.Recommendations = recs
+	}
+	job.FinishedAt = &now
+	snapshot := *job
+	r.mu.Unlock()
+
+	r.save(&snapshot)
+	time.AfterFunc(jobRetention, func() {
+		r.mu.Lock()
+		delete(r.jobs, id)
+		r.mu.Unlock()
+	})
+
+	if snapshot.CallbackURL != "" {
+		go r.notify(snapshot)
+	}
+}
+
+// save persiste el job; un error de persistencia no detiene el cálculo,
+// solo se registra.
+func (r *jobRegistry) save(job *AsyncJob) {
+	if r.opts.Store == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), jobStoreTimeout)
+	defer cancel()
+	if err := r.opts.Store.Save(ctx, job); err != nil {
+		log.Printf("[RECOMMEND] Error persistiendo el job %s: %v", job.ID, err)
+	}
+}
+
+// notify hace POST del job terminado a su callback, con reintentos y backoff
+// exponencial ante errores de red o respuestas que no son 2xx.
+func (r *jobRegistry) notify(job AsyncJob) {
+	body, err := json.Marshal(job)
+	if err != nil {
+		return
+	}
+
+	backoff := callbackBackoff
+	for attempt := 1; attempt <= callbackAttempts; attempt++ {
+		err = r.post(job.CallbackURL, body)
+		if err == nil {
+			log.Printf("[RECOMMEND] Callback del job %s notificado", job.ID)
+			return
+		}
+		log.Printf("[RECOMMEND] Callback del job %s falló (intento %d/%d): %v", job.ID, attempt, callbackAttempts, err)
+		if attempt < callbackAttempts {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+}
+
+func (r *jobRegistry) post(callbackURL string, body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), callbackTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, callbackURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -3,38 +3,73 @@ package recommend
 import (
 	"context"
 	"goflix/pkg/types"
-	"strconv"
+	"log"
+	"sort"
 )
 
 // Service defines the contract for recommendation logic.
 type Service interface {
-	RecommendForUser(userID int, topN int) ([]types.Result, error)
+	RecommendForUser(ctx context.Context, userID int, topN int) ([]types.Prediction, error)
 	GetPopularMovies(ctx context.Context, topN int) ([]Movie, error)
 	GetRecommendationsWithDetails(ctx context.Context, userID int, topN int) ([]RecommendedMovie, error)
+	// SubmitRecommendation lanza el cálculo en segundo plano y devuelve el job
+	// para consultarlo luego con GetJob. Si callbackURL no es vacía, se le
+	// hace POST con el job al terminar.
+	SubmitRecommendation(ctx context.Context, userID int, topN int, callbackURL string) (AsyncJob, error)
+	GetJob(ctx context.Context, id string) (AsyncJob, bool)
+	// ResumeJobs relanza los pedidos asíncronos que quedaron sin terminar
+	// en el store, por ejemplo porque el coordinador se reinició.
+	ResumeJobs(ctx context.Context) error
+	// StreamRecommendations calcula las recomendaciones notificando a
+	// onProgress cada bloque que completa un worker.
+	StreamRecommendations(ctx context.Context, userID int, topN int, onProgress ProgressFunc) ([]RecommendedMovie, error)
 }
 
 type RecommendedMovie struct {
 	Movie
-	Score float64 `json:"score"`
+	Score     float64  `json:"score"`
+	Neighbors []string `json:"neighbors"` // usuarios similares que aportaron a la predicción
 }
 
-type DispatchFunc func(int, int) ([]types.Result, error)
+// Progress es el avance de un cálculo distribuido tras cada RESULT.
+type Progress struct {
+	Done        int                `json:"done"`
+	Total       int                `json:"total"`
+	Block       types.Block        `json:"block_id"`
+	Partial     bool               `json:"partial,omitempty"`
+	Predictions []types.Prediction `json:"predictions"` // top-N combinado hasta el momento
+}
+
+// ProgressFunc recibe el avance de un cálculo distribuido.
+type ProgressFunc func(Progress)
+
+// DispatchFunc ejecuta el cálculo distribuido y devuelve las películas con
+// rating predicho para el usuario. El deadline de ctx llega hasta los
+// workers; si vence, se devuelve lo calculado hasta ese momento. progress
+// puede ser nil.
+type DispatchFunc func(ctx context.Context, userID int, topN int, progress ProgressFunc) ([]types.Prediction, error)
 
 type recomendService struct {
 	dispatch DispatchFunc
 	repo     Repository
+	jobs     *jobRegistry
 }
 
-func NewService(dispatch DispatchFunc, repo Repository) Service {
+func NewService(dispatch DispatchFunc, repo Repository, async AsyncOptions) Service {
 	return &recomendService{
 		dispatch: dispatch,
 		repo:     repo,
+		jobs:     newJobRegistry(async),
 	}
 }
 
-func (m *recomendService) RecommendForUser(userID int, topN int) ([]types.Result, error) {
+func (m *recomendService) RecommendForUser(ctx context.Context, userID int, topN int) ([]types.Prediction, error) {
+	return m.recommend(ctx, userID, topN, nil)
+}
+
+func (m *recomendService) recommend(ctx context.Context, userID int, topN int, progress ProgressFunc) ([]types.Prediction, error) {
 	if m.dispatch != nil {
-		results, err := m.dispatch(userID, topN)
+		results, err := m.dispatch(ctx, userID, topN, progress)
 		if err != nil {
 			return nil, err
 		}
@@ -49,31 +84,30 @@ func (m *recomendService) GetPopularMovies(ctx context.Context, topN int) ([]Mov
 }
 
 func (m *recomendService) GetRecommendationsWithDetails(ctx context.Context, userID int, topN int) ([]RecommendedMovie, error) {
-	// 1. Get raw recommendations
-	results, err := m.RecommendForUser(userID, topN)
+	return m.recommendWithDetails(ctx, userID, topN, nil)
+}
+
+func (m *recomendService) StreamRecommendations(ctx context.Context, userID int, topN int, onProgress ProgressFunc) ([]RecommendedMovie, error) {
+	return m.recommendWithDetails(ctx, userID, topN, onProgress)
+}
+
+func (m *recomendService) recommendWithDetails(ctx context.Context, userID int, topN int, progress ProgressFunc) ([]RecommendedMovie, error) {
+	// 1. Get predicted movies
+	predictions, err := m.recommend(ctx, userID, topN, progress)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(results) == 0 {
+	if len(predictions) == 0 {
 		return []RecommendedMovie{}, nil
 	}
 
-	// Flatten results to get all neighbors
-	var neighbors []types.Neighbor
-	for _, res := range results {
-		neighbors = append(neighbors, res.Neighbors...)
-	}
-
 	// 2. Extract IDs
-	var movieIDs []int
-	scoreMap := make(map[int]float64)
-	for _, n := range neighbors {
-		id, err := strconv.Atoi(n.ID)
-		if err == nil {
-			movieIDs = append(movieIDs, id)
-			scoreMap[id] = n.Similarity
-		}
+	movieIDs := make([]int, 0, len(predictions))
+	predMap := make(map[int]types.Prediction, len(predictions))
+	for _, p := range predictions {
+		movieIDs = append(movieIDs, p.MovieID)
+		predMap[p.MovieID] = p
 	}
 
 	// 3. Fetch movies
@@ -85,12 +119,56 @@ func (m *recomendService) GetRecommendationsWithDetails(ctx context.Context, use
 	// 4. Map to RecommendedMovie
 	var recommended []RecommendedMovie
 	for _, movie := range movies {
-		score := scoreMap[movie.MovieID]
+		p := predMap[movie.MovieID]
 		recommended = append(recommended, RecommendedMovie{
-			Movie: movie,
-			Score: score,
+			Movie:     movie,
+			Score:     p.Score,
+			Neighbors: p.Neighbors,
 		})
 	}
 
 	return recommended, nil
 }
+
+func (m *recomendService) SubmitRecommendation(ctx context.Context, userID int, topN int, callbackURL string) (AsyncJob, error) {
+	if err := m.jobs.validateCallbackURL(ctx, callbackURL); err != nil {
+		return AsyncJob{}, err
+	}
+
+	job, err := m.jobs.create(userID, topN, callbackURL)
+	if err != nil {
+		return AsyncJob{}, err
+	}
+	go m.runJob(job.ID, userID, topN)
+	return *job, nil
+}
+
+func (m *recomendService) runJob(id string, userID, topN int) {
+	m.jobs.setRunning(id)
+	// el job no depende de la conexión HTTP que lo creó; el deadline lo
+	// pone el dispatcher (DISPATCHER_RESULT_TIMEOUT)
+	recs, err := m.GetRecommendationsWithDetails(context.Background(), userID, topN)
+	sort.Slice(recs, func(i, j int) bool { return recs[i].Score > recs[j].Score })
+	m.jobs.finish(id, recs, err)
+}
+
+func (m *recomendService) GetJob(ctx context.Context, id string) (AsyncJob, bool) {
+	return m.jobs.get(ctx, id)
+}
+
+func (m *recomendService) ResumeJobs(ctx context.Context) error {
+	store := m.jobs.opts.Store
+	if store == nil {
+		return nil
+	}
+	jobs, err := store.Unfinished(ctx)
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		log.Printf("[RECOMMEND] Reanudando el job %s de userID=%d", job.ID, job.UserID)
+		m.jobs.add(job)
+		go m.runJob(job.ID, job.UserID, job.TopN)
+	}
+	return nil
+}
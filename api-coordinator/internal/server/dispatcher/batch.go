@@ -0,0 +1,159 @@
+package dispatcher
+
+import (
+	"context"
+	"fmt"
+
+	"goflix/pkg/types"
+)
+
+// BatchSink recibe las predicciones de cada usuario de un lote a medida que
+// se completan.
+type BatchSink func(userID int, predictions []types.Prediction)
+
+// RecommendBatch calcula recomendaciones para muchos usuarios. Los usuarios
+// se agrupan en lotes de batchSize: cada shard viaja una sola vez por lote,
+// con los vectores de todos los usuarios del lote, y el worker los puntúa en
+// una sola pasada sobre sus candidatos. Si hay JobStore, el progreso se
+// persiste al terminar cada lote.
+func (d *Dispatcher) RecommendBatch(ctx context.Context, opts JobOptions, userIDs []int, k, topN, batchSize int, sink BatchSink) error {
+	ds := d.currentDataset()
+	if ds == nil {
+		return fmt.Errorf("dataset no cargado")
+	}
+	rec := newJobRecord(JobKindBatch, opts, userIDs, k, topN, batchSize, ds.epoch)
+	return d.ResumeJob(ctx, rec, sink)
+}
+
+// Precompute calcula recomendaciones para todos los usuarios del dataset con
+// prioridad background, así nunca compite con los pedidos interactivos.
+func (d *Dispatcher) Precompute(ctx context.Context, k, topN, batchSize int, sink BatchSink) error {
+	ds := d.currentDataset()
+	if ds == nil {
+		return fmt.Errorf("dataset no cargado")
+	}
+	opts := JobOptions{Priority: PriorityBackground, Tenant: "precompute"}
+	rec := newJobRecord(JobKindPrecompute, opts, ds.userIDs, k, topN, batchSize, ds.epoch)
+	return d.ResumeJob(ctx, rec, sink)
+}
+
+// ResumeJob procesa los lotes de rec a partir de rec.Next. Se usa tanto para
+// jobs nuevos como para los que quedaron sin terminar antes de un reinicio.
+// Si ctx se cancela el job queda en curso en el store para reanudarlo luego.
+func (d *Dispatcher) ResumeJob(ctx context.Context, rec *JobRecord, sink BatchSink) error {
+	ds := d.currentDataset()
+	if ds == nil {
+		return fmt.Errorf("dataset no cargado")
+	}
+	if rec.Epoch != ds.epoch {
+		fmt.Println("Job", rec.ID, ": arrancó con la época", rec.Epoch, "y continúa con", ds.epoch)
+	}
+	batchSize := rec.BatchSize
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	opts := JobOptions{Priority: rec.Priority, Tenant: rec.Tenant}
+
+	if rec.UserIDs == nil && rec.Kind == JobKindPrecompute {
+		// los precálculos no guardan la lista: son todos los usuarios del dataset
+		rec.UserIDs = ds.userIDs
+		rec.Users = len(ds.userIDs)
+	}
+	if rec.Status == JobPending {
+		d.saveJobUsers(ctx, rec)
+	}
+	rec.Status = JobRunning
+	d.saveJob(ctx, rec)
+
+	for lote := 1; rec.Next < len(rec.UserIDs); lote++ {
+		end := rec.Next + fitBatch(ds, rec.UserIDs[rec.Next:], batchSize, d.minMemoryBudget())
+		batch := rec.UserIDs[rec.Next:end]
+
+		resultsCh := make(chan Result, len(ds.shards))
+		count, err := d.run(ctx, opts, ds, batch, true, rec.K, resultsCh)
+		if err != nil {
+			return d.failJob(ctx, rec, err)
+		}
+		if count == 0 {
+			return d.failJob(ctx, rec, fmt.Errorf("no hay workers conectados"))
+		}
+
+		results := make([]Result, 0, count)
+		for i := 0; i < count; i++ {
+			select {
+			case res := <-resultsCh:
+				results = append(results, res)
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		neighbors := MergeBatch(results, rec.K)
+		for _, uid := range batch {
+			sink(uid, PredictMovies(ds.userRatings[uid], neighbors[uid], ds.userRatings, rec.TopN))
+		}
+
+		rec.TasksTotal += count
+		for _, res := range results {
+			if res.Error == "" {
+				rec.TasksDone++
+			}
+		}
+		fmt.Println("Lote", lote, ":", len(batch), "usuarios procesados")
+		rec.Next = end
+		d.saveJob(ctx, rec)
+	}
+
+	rec.Status = JobDone
+	d.saveJob(ctx, rec)
+	return nil
+}
+
+// fitBatch devuelve cuántos de los userIDs entran en el próximo lote: como
+// mucho batchSize y, si hay presupuesto de memoria, los que quepan en la
+// mitad que shardsFor deja para los objetivos. Siempre al menos uno; si ese
+// solo ya no entra, schedule devuelve el error del chunk.
+func fitBatch(ds *dataset, userIDs []int, batchSize int, budget int64) int {
+	n := batchSize
+	if n > len(userIDs) {
+		n = len(userIDs)
+	}
+	if budget <= 0 {
+		return n
+	}
+	ratings := 0
+	for i, id := range userIDs[:n] {
+		ratings += len(ds.userRatings[id])
+		if i > 0 && 2*types.RatingsMemory(ratings) > budget {
+			return i
+		}
+	}
+	return n
+}
+
+func (d *Dispatcher) failJob(ctx context.Context, rec *JobRecord, err error) error {
+	rec.Status = JobFailed
+	rec.Error = err.Error()
+	d.saveJob(ctx, rec)
+	return err
+}
+
+// MergeBatch separa los resultados de un lote por usuario objetivo y hace el
+// merge top-K de cada uno.
+func MergeBatch(results []Result, k int) map[int][]types.Neighbor {
+	perTarget := make(map[int][]Result)
+	for _, res := range results {
+		if res.Error != "" {
+			continue
+		}
+		for _, tn := range res.Batch {
+			perTarget[tn.UserID] = append(perTarget[tn.UserID], Result{Neighbors: tn.Neighbors})
+		}
+	}
+
+	merged := make(map[int][]types.Neighbor, len(perTarget))
+	for uid, rs := range perTarget {
+		merged[uid] = MergeTopK(rs, k)
+	}
+	return merged
+}
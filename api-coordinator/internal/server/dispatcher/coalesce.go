@@ -0,0 +1,84 @@
+package dispatcher
+
+import (
+	"context"
+	"sync"
+
+	"goflix/pkg/types"
+)
+
+// Coalescer agrupa pedidos idénticos concurrentes en un solo job, al estilo
+// de singleflight. El job corre con su propio contexto: hereda el deadline
+// del primer pedido y solo se cancela cuando todos los pedidos que lo
+// esperan se cancelaron.
+type Coalescer struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+type call struct {
+	done    chan struct{}
+	preds   []types.Prediction
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+func NewCoalescer() *Coalescer {
+	return &Coalescer{calls: make(map[string]*call)}
+}
+
+// Do ejecuta fn una sola vez por key mientras haya un job en curso. shared
+// indica si el resultado vino de un job lanzado por otro pedido.
+func (c *Coalescer) Do(ctx context.Context, key string, fn func(ctx context.Context) ([]types.Prediction, error)) (preds []types.Prediction, shared bool, err error) {
+	c.mu.Lock()
+	if cl, ok := c.calls[key]; ok {
+		cl.waiters++
+		c.mu.Unlock()
+		return c.wait(ctx, key, cl, true)
+	}
+
+	// el job no muere si se cancela solo uno de los pedidos que lo esperan
+	base := context.WithoutCancel(ctx)
+	var jobCtx context.Context
+	var cancel context.CancelFunc
+	if deadline, ok := ctx.Deadline(); ok {
+		jobCtx, cancel = context.WithDeadline(base, deadline)
+	} else {
+		jobCtx, cancel = context.WithCancel(base)
+	}
+	cl := &call{done: make(chan struct{}), waiters: 1, cancel: cancel}
+	c.calls[key] = cl
+	c.mu.Unlock()
+
+	go func() {
+		cl.preds, cl.err = fn(jobCtx)
+		c.mu.Lock()
+		if c.calls[key] == cl {
+			delete(c.calls, key)
+		}
+		c.mu.Unlock()
+		cancel()
+		close(cl.done)
+	}()
+	return c.wait(ctx, key, cl, false)
+}
+
+func (c *Coalescer) wait(ctx context.Context, key string, cl *call, shared bool) ([]types.Prediction, bool, error) {
+	select {
+	case <-cl.done:
+		return cl.preds, shared, cl.err
+	case <-ctx.Done():
+		c.mu.Lock()
+		cl.waiters--
+		if cl.waiters == 0 {
+			// nadie más espera este job: cancelarlo libera sus chunks en cola
+			if c.calls[key] == cl {
+				delete(c.calls, key)
+			}
+			cl.cancel()
+		}
+		c.mu.Unlock()
+		return nil, shared, ctx.Err()
+	}
+}
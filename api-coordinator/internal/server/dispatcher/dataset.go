@@ -0,0 +1,216 @@
+package dispatcher
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+
+	"goflix/pkg/types"
+)
+
+// dataset es la matriz usuario-película que se reparte en shards estables
+// entre los workers. La época identifica su contenido: mientras no cambie,
+// los workers reutilizan los shards que ya recibieron.
+type dataset struct {
+	epoch       string
+	userRatings map[int]map[int]float64
+	userIDs     []int // ordenados
+	shards      []*shard
+	work        int // trabajo total, ~ cantidad de ratings
+}
+
+// shard es un rango contiguo de dataset.userIDs.
+type shard struct {
+	id    int
+	block types.Block // índices [StartID, EndID] dentro de dataset.userIDs
+	work  int
+	hash  string // hash del contenido; los workers cachean los shards por hash
+}
+
+func newDataset(userRatings map[int]map[int]float64, userIDs []int, numShards int) *dataset {
+	ids := make([]int, len(userIDs))
+	copy(ids, userIDs)
+	sort.Ints(ids)
+
+	ds := &dataset{
+		epoch:       hashRatings(userRatings, ids),
+		userRatings: userRatings,
+		userIDs:     ids,
+	}
+
+	// shards de trabajo estimado parecido
+	work := estimateWork(ids, userRatings)
+	for i, b := range partitionByWork(work, numShards) {
+		shardWork := 0
+		for _, w := range work[b[0]:b[1]] {
+			shardWork += w
+		}
+		ds.work += shardWork
+		ds.shards = append(ds.shards, &shard{
+			id:    i,
+			block: types.Block{StartID: b[0], EndID: b[1] - 1},
+			work:  shardWork,
+			hash:  hashRatings(userRatings, ids[b[0]:b[1]]),
+		})
+	}
+	return ds
+}
+
+// hashRatings calcula un hash estable de los ratings de userIDs. Es la época
+// del dataset completo y la clave de caché de cada shard, de modo que
+// reiniciar el coordinador o recargar datos con pocos cambios no invalida
+// los shards que los workers ya tienen.
+func hashRatings(userRatings map[int]map[int]float64, userIDs []int) string {
+	h := fnv.New64a()
+	buf := make([]byte, 8)
+	write := func(v uint64) {
+		binary.BigEndian.PutUint64(buf, v)
+		h.Write(buf)
+	}
+
+	movies := make([]int, 0, 64)
+	for _, uid := range userIDs {
+		ratings := userRatings[uid]
+		movies = movies[:0]
+		for mid := range ratings {
+			movies = append(movies, mid)
+		}
+		sort.Ints(movies)
+
+		write(uint64(uid))
+		write(uint64(len(movies)))
+		for _, mid := range movies {
+			write(uint64(mid))
+			write(math.Float64bits(ratings[mid]))
+		}
+	}
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// shardData arma el payload de LOAD_SHARD para un shard.
+func (ds *dataset) shardData(s *shard) types.ShardData {
+	ratings := make(map[int]map[int]float64, s.block.EndID-s.block.StartID+1)
+	for _, id := range ds.userIDs[s.block.StartID : s.block.EndID+1] {
+		ratings[id] = ds.userRatings[id]
+	}
+	return types.ShardData{
+		Epoch:   ds.epoch,
+		ShardID: s.id,
+		Hash:    s.hash,
+		Ratings: ratings,
+	}
+}
+
+// shardsFor devuelve cuántos shards usar para que cada uno ocupe a lo sumo la
+// mitad de budget; la otra mitad queda para los objetivos del TASK. Nunca
+// baja de numShards ni supera la cantidad de usuarios.
+func shardsFor(work, users, numShards int, budget int64) int {
+	n := numShards
+	if budget > 0 {
+		if need := int((2*types.RatingsMemory(work) + budget - 1) / budget); need > n {
+			n = need
+		}
+	}
+	if n > users {
+		n = users
+	}
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// minMemoryBudget devuelve el presupuesto de memoria más chico entre los
+// workers conectados que anunciaron uno (0 si ninguno lo hizo).
+func (d *Dispatcher) minMemoryBudget() int64 {
+	d.server.Mu.RLock()
+	defer d.server.Mu.RUnlock()
+	var min int64
+	for _, w := range d.server.Workers {
+		if w.MemoryBudget > 0 && (min == 0 || w.MemoryBudget < min) {
+			min = w.MemoryBudget
+		}
+	}
+	return min
+}
+
+// fitDataset vuelve a partir el dataset vigente en más shards si los actuales
+// no entran en la memoria del worker más chico, por ejemplo cuando se conecta
+// un worker con menos memoria que los anteriores. Los jobs en curso siguen
+// con los shards viejos.
+func (d *Dispatcher) fitDataset() {
+	budget := d.minMemoryBudget()
+	ds := d.currentDataset()
+	if ds == nil || budget <= 0 {
+		return
+	}
+	n := shardsFor(ds.work, len(ds.userIDs), d.numShards, budget)
+	if n <= len(ds.shards) {
+		return
+	}
+
+	resized := newDataset(ds.userRatings, ds.userIDs, n)
+	d.mu.Lock()
+	if d.data == ds {
+		d.data = resized
+	}
+	d.mu.Unlock()
+	fmt.Println("Dataset re-particionado en", len(resized.shards), "shards para un presupuesto de", budget>>20, "MB por tarea")
+}
+
+// LoadDataset reemplaza la matriz de ratings que usan los jobs nuevos. Los
+// jobs en curso terminan con la versión anterior.
+func (d *Dispatcher) LoadDataset(userRatings map[int]map[int]float64, userIDs []int) {
+	work := 0
+	for _, w := range estimateWork(userIDs, userRatings) {
+		work += w
+	}
+	ds := newDataset(userRatings, userIDs, shardsFor(work, len(userIDs), d.numShards, d.minMemoryBudget()))
+	d.mu.Lock()
+	d.data = ds
+	d.mu.Unlock()
+	fmt.Println("Dataset cargado: época", ds.epoch, "con", len(ds.userIDs), "usuarios en", len(ds.shards), "shards")
+}
+
+// Epoch devuelve la versión del dataset vigente ("" si no hay ninguno).
+func (d *Dispatcher) Epoch() string {
+	if ds := d.currentDataset(); ds != nil {
+		return ds.epoch
+	}
+	return ""
+}
+
+func (d *Dispatcher) currentDataset() *dataset {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.data
+}
+
+// ensureShard envía LOAD_SHARD al worker si todavía no le envió un shard con
+// ese contenido. Si el worker lo descartó de su caché responde shard_missing
+// y handleResult lo olvida para reenviarlo. Debe llamarse con d.mu tomado.
+func (d *Dispatcher) ensureShard(workerID string, ds *dataset, s *shard) error {
+	loaded := d.loaded[workerID]
+	if loaded[s.hash] {
+		return nil
+	}
+
+	data, err := json.Marshal(ds.shardData(s))
+	if err != nil {
+		return err
+	}
+	if err := d.send(workerID, types.Message{Type: "LOAD_SHARD", Data: data}); err != nil {
+		return err
+	}
+
+	if loaded == nil {
+		loaded = make(map[string]bool)
+		d.loaded[workerID] = loaded
+	}
+	loaded[s.hash] = true
+	fmt.Println("Shard", s.id, "(hash", s.hash+") de época", ds.epoch, "enviado a worker", workerID)
+	return nil
+}
@@ -4,12 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"sort"
 	"sync"
 	"time"
 
 	tcpserver "goflix/api-coordinator/internal/server/tcp"
 	"goflix/pkg/types"
+
+	"github.com/google/uuid"
 )
 
 type (
@@ -18,141 +19,127 @@ type (
 )
 
 type Dispatcher struct {
-	server       *tcpserver.Server
-	timeouts     time.Duration
-	resultsChans map[string]chan types.Result
-	mu           sync.Mutex
+	server    *tcpserver.Server
+	timeouts  time.Duration // espera máxima por el RESULT de un chunk
+	numShards int
+	scheduler Scheduler
+	data      *dataset                   // matriz de ratings vigente
+	loaded    map[string]map[string]bool // worker -> hashes de shards enviados
+	queue     *fairQueue                 // chunks pendientes por prioridad y tenant
+	inflight  map[string]*assignment     // chunks enviados esperando RESULT
+	ready     []string                   // workers que pidieron trabajo (PULL)
+	stats     map[string]*workerStats
+	store     JobStore      // persistencia de jobs de fondo (opcional)
+	scoring   types.Scoring // métrica de similitud que viaja en cada TASK
+	recorder  *Recorder     // grabación de TASK/RESULT (opcional)
+	mu        sync.Mutex
 }
 
-func New(server *tcpserver.Server, timeout time.Duration) *Dispatcher {
+// New crea el dispatcher. chunkTimeout es cuánto se espera el RESULT de un
+// chunk antes de reencolarlo; conviene que sea bastante menor que el deadline
+// de los pedidos, así un chunk perdido se rehace a tiempo.
+func New(server *tcpserver.Server, chunkTimeout time.Duration, numShards int, scheduler Scheduler) *Dispatcher {
+	if numShards < 1 {
+		numShards = 1
+	}
+	if scheduler == nil {
+		scheduler = &RoundRobin{}
+	}
 	d := &Dispatcher{
-		server:       server,
-		timeouts:     timeout,
-		resultsChans: make(map[string]chan types.Result),
+		server:    server,
+		timeouts:  chunkTimeout,
+		numShards: numShards,
+		scheduler: scheduler,
+		loaded:    make(map[string]map[string]bool),
+		queue:     newFairQueue(),
+		inflight:  make(map[string]*assignment),
+		stats:     make(map[string]*workerStats),
 	}
 	go d.processIncoming()
+	go d.reapLostChunks()
 	return d
 }
 
-func (d *Dispatcher) Run(ctx context.Context, userID int, userRatings map[int]map[int]float64, topN int, resultsCh chan<- Result) (int, error) {
-	fmt.Println("Dispatcher Run started for userID:", userID)
-	if d.resultsChans == nil {
-		d.resultsChans = make(map[string]chan types.Result)
-	}
+// run encola un chunk por cada shard de ds para uno o varios usuarios
+// objetivo, según la prioridad y el tenant de opts. Los workers los van
+// tomando con PULL a medida que terminan, así los más rápidos procesan más
+// chunks. En modo batch cada chunk lleva los vectores de todos y el worker
+// devuelve vecinos por objetivo. Devuelve la cantidad de chunks: por cada uno
+// se enviará exactamente un Result a resultsCh.
+func (d *Dispatcher) run(ctx context.Context, opts JobOptions, ds *dataset, targetIDs []int, batch bool, topN int, resultsCh chan<- Result) (int, error) {
+	fmt.Println("Dispatcher Run started for", len(targetIDs), "users, priority:", opts.Priority, "tenant:", opts.Tenant)
 
 	d.server.Mu.RLock()
-	idleWorkers := make([]string, 0)
-	for id, w := range d.server.Workers {
-		if w.State == types.WorkerIdle {
-			idleWorkers = append(idleWorkers, id)
-		}
-	}
+	numWorkers := len(d.server.Workers)
 	d.server.Mu.RUnlock()
-	fmt.Println("Idle workers found:", len(idleWorkers))
 
-	if len(idleWorkers) == 0 {
-		fmt.Println("No idle workers, returning")
+	if numWorkers == 0 {
+		fmt.Println("No workers connected, returning")
 		return 0, nil
 	}
 
-	userIDs := make([]int, 0, len(userRatings)-1)
-	for id := range userRatings {
-		if id != userID {
-			userIDs = append(userIDs, id)
-		}
+	d.mu.Lock()
+	scoring := d.scoring
+	d.mu.Unlock()
+
+	j := &job{
+		ctx:       ctx,
+		scoring:   scoring,
+		algorithm: types.AlgoUserNeighbors,
+		id:        uuid.New().String(),
+		opts:      opts,
+		data:      ds,
+		topN:      topN,
+		targetIDs: targetIDs,
+		batch:     batch,
+		resultsCh: resultsCh,
 	}
-	sort.Ints(userIDs)
-
-	numBlocks := len(idleWorkers)
-	if numBlocks > len(userIDs) {
-		numBlocks = len(userIDs)
-		idleWorkers = idleWorkers[:numBlocks]
+	chunks := make([]*chunk, 0, len(ds.shards))
+	for _, s := range ds.shards {
+		chunks = append(chunks, &chunk{job: j, shard: s})
 	}
-	blockSize := len(userIDs) / numBlocks
-	remainder := len(userIDs) % numBlocks
-	fmt.Println("Particionando", len(userIDs), "usuarios en", numBlocks, "bloques")
-
-	// jobID := uuid.New().String()
-	startIdx := 0
-	dispatchedCount := 0
+	fmt.Println("Job", j.id, ":", len(ds.userIDs), "usuarios en", len(chunks), "chunks")
 
-	targetRatings := userRatings[userID]
-
-	for i := 0; i < numBlocks; i++ {
-		endIdx := startIdx + blockSize
-		if i < remainder {
-			endIdx++
-		}
-		workerID := idleWorkers[i]
-
-		// Extract candidate ratings for this block
-		candidateRatings := make(map[int]map[int]float64)
-		// The block is defined by startIdx and endIdx in the userIDs slice
-		// userIDs contains the IDs of all candidates
-		currentBlockIDs := userIDs[startIdx:endIdx]
-		for _, id := range currentBlockIDs {
-			candidateRatings[id] = userRatings[id]
-		}
-
-		task := types.Task{
-			JobID:            workerID,
-			BlockID:          types.Block{StartID: startIdx, EndID: endIdx - 1},
-			K:                topN,
-			TargetRatings:    targetRatings,
-			CandidateRatings: candidateRatings,
-		}
-		fmt.Println("Creando tarea para worker", workerID, "con block StartID:", startIdx, "EndID:", endIdx-1)
-		ch := make(chan types.Result, 1)
-		d.mu.Lock()
-		d.resultsChans[workerID] = ch
-		d.mu.Unlock()
-
-		// mandar tarea al worker
-		err := d.DispatchTask(workerID, task)
-		if err != nil {
-			fmt.Println("Error dispatching task to worker", workerID, ":", err)
-			d.mu.Lock()
-			delete(d.resultsChans, workerID)
-			d.mu.Unlock()
-			continue
-		}
+	d.mu.Lock()
+	for _, c := range chunks {
+		d.queue.Push(c)
+	}
+	d.mu.Unlock()
 
-		// establaecer como ocupados
-		d.server.Mu.Lock()
-		d.server.Workers[workerID].State = types.WorkerBusy
-		d.server.Mu.Unlock()
-		fmt.Println("Worker", workerID, "marcado como busy")
-
-		go func(wID string, c chan types.Result, jID string) {
-			fmt.Println("Esperando resultado para job", jID, "en worker", wID)
-			select {
-			case res := <-c:
-				fmt.Println("Recibido resultado para job", jID, "en worker", wID)
-				resultsCh <- res
-				d.server.Mu.Lock()
-				d.server.Workers[wID].State = types.WorkerIdle
-				d.server.Mu.Unlock()
-				fmt.Println("Worker", wID, "marcado como idle")
-			case <-time.After(d.timeouts):
-				fmt.Println("Timeout esperando resultado para job", jID, "en worker", wID)
-				d.server.Mu.Lock()
-				d.server.Workers[wID].State = types.WorkerIdle
-				d.server.Mu.Unlock()
-			}
-			d.mu.Lock()
-			delete(d.resultsChans, jID)
-			d.mu.Unlock()
-		}(workerID, ch, workerID)
+	d.schedule()
+	return len(chunks), nil
+}
 
-		startIdx = endIdx
-		dispatchedCount++
+// SetScoring define la métrica de similitud de los jobs siguientes; los jobs
+// en curso conservan la que tenían al empezar.
+func (d *Dispatcher) SetScoring(scoring types.Scoring) error {
+	switch scoring.Sim {
+	case "", types.SimCosine, types.SimPearson, types.SimAdjustedCosine, types.SimJaccard, types.SimEuclidean:
+	default:
+		return fmt.Errorf("métrica de similitud desconocida: %q", scoring.Sim)
+	}
+	if scoring.Shrinkage < 0 {
+		return fmt.Errorf("shrinkage inválido: %g", scoring.Shrinkage)
+	}
+	if scoring.MinOverlap < 0 || scoring.Significance < 0 {
+		return fmt.Errorf("min_overlap y significance no pueden ser negativos")
 	}
-	return dispatchedCount, nil
+	d.mu.Lock()
+	d.scoring = scoring
+	d.mu.Unlock()
+	return nil
+}
+
+// Scoring devuelve la métrica de similitud vigente.
+func (d *Dispatcher) Scoring() types.Scoring {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.scoring
 }
 
 func (d *Dispatcher) processIncoming() {
 	// procesa los mensajes de los workers, para cuando ya retornan los resultados
-	// y también gestiona los heartbeats para monitorear el estado
+	// o piden el siguiente chunk
 	for env := range d.server.Incoming {
 		switch env.Msg.Type {
 		case "RESULT":
@@ -161,36 +148,31 @@ func (d *Dispatcher) processIncoming() {
 				continue
 			}
 			fmt.Println("Procesando RESULT para job", result.JobID, "de worker", env.WorkerID)
-			d.mu.Lock()
-			ch, ok := d.resultsChans[env.WorkerID]
-			d.mu.Unlock()
-			if ok {
-				select {
-				case ch <- result:
-				default:
-				}
-			}
+			d.handleResult(env.WorkerID, result)
+		case "PULL":
+			d.handlePull(env.WorkerID)
 		}
 	}
 }
 
-func (d *Dispatcher) DispatchTask(WorkerID string, task types.Task) (err error) {
+func (d *Dispatcher) DispatchTask(WorkerID string, task types.Task) error {
 	// manda la tarea a un worker especifico
 	fmt.Println("Dispatching task to worker", WorkerID)
+	data, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+	return d.send(WorkerID, types.Message{Type: "TASK", Data: data})
+}
+
+// send encola un mensaje en el canal de salida del worker sin bloquear.
+func (d *Dispatcher) send(WorkerID string, msg types.Message) (err error) {
 	d.server.Mu.RLock()
 	worker, ok := d.server.Workers[WorkerID]
 	d.server.Mu.RUnlock()
 	if !ok {
 		return fmt.Errorf("worker not found")
 	}
-	data, err := json.Marshal(task)
-	if err != nil {
-		return err
-	}
-	msg := types.Message{
-		Type: "TASK",
-		Data: data,
-	}
 	defer func() {
 		if r := recover(); r != nil {
 			err = fmt.Errorf("worker %s channel closed", WorkerID)
@@ -0,0 +1,266 @@
+package dispatcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// JobStatus es el estado persistido de un job.
+type JobStatus string
+
+const (
+	JobPending JobStatus = "pending"
+	JobRunning JobStatus = "running"
+	JobDone    JobStatus = "done"
+	JobFailed  JobStatus = "failed"
+)
+
+// Tipos de job persistidos.
+const (
+	JobKindBatch      = "batch"
+	JobKindPrecompute = "precompute"
+)
+
+// JobRecord es el estado de un job de fondo que sobrevive a un reinicio del
+// coordinador. Los lotes se confirman de a uno: al reanudar se sigue desde
+// Next, así solo se repite el lote que estaba en curso. La lista de usuarios
+// no viaja en el registro, que se reescribe tras cada lote: un job por lotes
+// la guarda una sola vez aparte y un precálculo la rearma del dataset.
+type JobRecord struct {
+	ID         string    `json:"id"`
+	Kind       string    `json:"kind"` // JobKindBatch | JobKindPrecompute
+	Priority   Priority  `json:"priority"`
+	Tenant     string    `json:"tenant"`
+	UserIDs    []int     `json:"-"`
+	Users      int       `json:"users"` // cantidad de usuarios del job
+	K          int       `json:"k"`
+	TopN       int       `json:"top_n"`
+	BatchSize  int       `json:"batch_size"`
+	Epoch      string    `json:"epoch"` // época del dataset con la que arrancó
+	Status     JobStatus `json:"status"`
+	Error      string    `json:"error,omitempty"`
+	Next       int       `json:"next"`        // índice del primer usuario sin procesar
+	TasksTotal int       `json:"tasks_total"` // chunks enviados en los lotes terminados
+	TasksDone  int       `json:"tasks_done"`  // chunks con RESULT en los lotes terminados
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Finished indica si el job ya no va a avanzar.
+func (r *JobRecord) Finished() bool {
+	return r.Status == JobDone || r.Status == JobFailed
+}
+
+var (
+	// ErrJobNotFound se devuelve al consultar un job que no existe o expiró.
+	ErrJobNotFound = errors.New("job no encontrado")
+	// ErrNoJobStore se devuelve al consultar un job sin persistencia activa.
+	ErrNoJobStore = errors.New("persistencia de jobs desactivada")
+)
+
+// JobStore persiste el estado de los jobs de fondo.
+type JobStore interface {
+	Save(ctx context.Context, rec *JobRecord) error
+	Get(ctx context.Context, id string) (*JobRecord, error)
+	// SaveUsers guarda la lista de usuarios de un job por lotes; se llama una
+	// sola vez, al crearlo.
+	SaveUsers(ctx context.Context, id string, userIDs []int) error
+	// Unfinished devuelve los jobs pendientes o en curso, para reanudarlos,
+	// con la lista de usuarios de los jobs por lotes.
+	Unfinished(ctx context.Context) ([]*JobRecord, error)
+}
+
+const (
+	redisJobKeyPrefix    = "job:"
+	redisJobUsersSuffix  = ":users"
+	redisActiveJobsKey   = "jobs:active"
+	redisJobWriteTimeout = 2 * time.Second
+	redisFinishedJobTTL  = 24 * time.Hour // tiempo que un cliente puede consultar el estado final
+)
+
+// RedisJobStore guarda cada job como JSON en job:<id>, la lista de usuarios de
+// los jobs por lotes en job:<id>:users y mantiene el índice jobs:active con
+// los que aún no terminaron.
+type RedisJobStore struct {
+	client *redis.Client
+}
+
+func NewRedisJobStore(client *redis.Client) *RedisJobStore {
+	return &RedisJobStore{client: client}
+}
+
+func (s *RedisJobStore) Save(ctx context.Context, rec *JobRecord) error {
+	rec.UpdatedAt = time.Now()
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, redisJobWriteTimeout)
+	defer cancel()
+
+	pipe := s.client.TxPipeline()
+	if rec.Finished() {
+		pipe.Set(ctx, redisJobKeyPrefix+rec.ID, data, redisFinishedJobTTL)
+		pipe.Del(ctx, redisJobKeyPrefix+rec.ID+redisJobUsersSuffix)
+		pipe.SRem(ctx, redisActiveJobsKey, rec.ID)
+	} else {
+		pipe.Set(ctx, redisJobKeyPrefix+rec.ID, data, 0)
+		pipe.SAdd(ctx, redisActiveJobsKey, rec.ID)
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (s *RedisJobStore) Get(ctx context.Context, id string) (*JobRecord, error) {
+	data, err := s.client.Get(ctx, redisJobKeyPrefix+id).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rec JobRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+func (s *RedisJobStore) SaveUsers(ctx context.Context, id string, userIDs []int) error {
+	data, err := json.Marshal(userIDs)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, redisJobWriteTimeout)
+	defer cancel()
+	return s.client.Set(ctx, redisJobKeyPrefix+id+redisJobUsersSuffix, data, 0).Err()
+}
+
+func (s *RedisJobStore) users(ctx context.Context, id string) ([]int, error) {
+	data, err := s.client.Get(ctx, redisJobKeyPrefix+id+redisJobUsersSuffix).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var userIDs []int
+	if err := json.Unmarshal(data, &userIDs); err != nil {
+		return nil, err
+	}
+	return userIDs, nil
+}
+
+func (s *RedisJobStore) Unfinished(ctx context.Context) ([]*JobRecord, error) {
+	ids, err := s.client.SMembers(ctx, redisActiveJobsKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	recs := make([]*JobRecord, 0, len(ids))
+	for _, id := range ids {
+		rec, err := s.Get(ctx, id)
+		if errors.Is(err, ErrJobNotFound) {
+			s.client.SRem(ctx, redisActiveJobsKey, id)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if rec.Kind == JobKindBatch {
+			rec.UserIDs, err = s.users(ctx, id)
+			if errors.Is(err, ErrJobNotFound) {
+				fmt.Println("Job", id, "sin lista de usuarios, no se puede reanudar")
+				s.client.SRem(ctx, redisActiveJobsKey, id)
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		recs = append(recs, rec)
+	}
+	return recs, nil
+}
+
+// SetJobStore activa la persistencia de los jobs de fondo. Sin store los
+// jobs funcionan igual pero se pierden si el coordinador se reinicia.
+func (d *Dispatcher) SetJobStore(store JobStore) {
+	d.mu.Lock()
+	d.store = store
+	d.mu.Unlock()
+}
+
+// JobStatus devuelve el estado persistido de un job.
+func (d *Dispatcher) JobStatus(ctx context.Context, id string) (*JobRecord, error) {
+	store := d.jobStore()
+	if store == nil {
+		return nil, ErrNoJobStore
+	}
+	return store.Get(ctx, id)
+}
+
+// UnfinishedJobs devuelve los jobs que quedaron sin terminar, por ejemplo
+// porque el coordinador se reinició mientras corrían.
+func (d *Dispatcher) UnfinishedJobs(ctx context.Context) ([]*JobRecord, error) {
+	store := d.jobStore()
+	if store == nil {
+		return nil, nil
+	}
+	return store.Unfinished(ctx)
+}
+
+func (d *Dispatcher) jobStore() JobStore {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.store
+}
+
+// saveJobUsers persiste la lista de usuarios de un job por lotes nuevo. Los
+// precálculos no la guardan: al reanudarse usan los usuarios del dataset.
+func (d *Dispatcher) saveJobUsers(ctx context.Context, rec *JobRecord) {
+	store := d.jobStore()
+	if store == nil || rec.Kind != JobKindBatch {
+		return
+	}
+	if err := store.SaveUsers(context.WithoutCancel(ctx), rec.ID, rec.UserIDs); err != nil {
+		fmt.Println("Error persistiendo los usuarios del job", rec.ID, ":", err)
+	}
+}
+
+// saveJob persiste el estado del job; un error de persistencia no detiene
+// el cálculo, solo se registra.
+func (d *Dispatcher) saveJob(ctx context.Context, rec *JobRecord) {
+	store := d.jobStore()
+	if store == nil {
+		return
+	}
+	if err := store.Save(context.WithoutCancel(ctx), rec); err != nil {
+		fmt.Println("Error persistiendo job", rec.ID, ":", err)
+	}
+}
+
+func newJobRecord(kind string, opts JobOptions, userIDs []int, k, topN, batchSize int, epoch string) *JobRecord {
+	now := time.Now()
+	return &JobRecord{
+		ID:        uuid.New().String(),
+		Kind:      kind,
+		Priority:  opts.Priority,
+		Tenant:    opts.Tenant,
+		UserIDs:   userIDs,
+		Users:     len(userIDs),
+		K:         k,
+		TopN:      topN,
+		BatchSize: batchSize,
+		Epoch:     epoch,
+		Status:    JobPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
@@ -0,0 +1,82 @@
+package dispatcher
+
+import (
+	"container/heap"
+	"sort"
+	"strconv"
+
+	"goflix/pkg/types"
+)
+
+// neighborLess define el orden global de vecinos: mayor similitud primero y,
+// ante empates, menor ID. Así el merge no depende del orden de llegada.
+func neighborLess(a, b types.Neighbor) bool {
+	if a.Similarity != b.Similarity {
+		return a.Similarity > b.Similarity
+	}
+	ai, errA := strconv.Atoi(a.ID)
+	bi, errB := strconv.Atoi(b.ID)
+	if errA == nil && errB == nil {
+		return ai < bi
+	}
+	return a.ID < b.ID
+}
+
+// cursor apunta al siguiente vecino pendiente de la lista de un bloque.
+type cursor struct {
+	list []types.Neighbor
+	pos  int
+}
+
+type cursorHeap []*cursor
+
+func (h cursorHeap) Len() int { return len(h) }
+func (h cursorHeap) Less(i, j int) bool {
+	return neighborLess(h[i].list[h[i].pos], h[j].list[h[j].pos])
+}
+func (h cursorHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *cursorHeap) Push(x interface{}) { *h = append(*h, x.(*cursor)) }
+func (h *cursorHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
+
+// MergeTopK combina los vecinos de todos los bloques con un merge k-way y
+// devuelve los k mejores sin IDs repetidos. Si un ID aparece en varios
+// bloques se conserva su mayor similitud. Los bloques con error se ignoran.
+func MergeTopK(results []Result, k int) []types.Neighbor {
+	h := make(cursorHeap, 0, len(results))
+	for _, res := range results {
+		if res.Error != "" || len(res.Neighbors) == 0 {
+			continue
+		}
+		// los workers ya ordenan, pero no dependemos de eso para el merge
+		list := make([]types.Neighbor, len(res.Neighbors))
+		copy(list, res.Neighbors)
+		sort.Slice(list, func(i, j int) bool { return neighborLess(list[i], list[j]) })
+		h = append(h, &cursor{list: list})
+	}
+	heap.Init(&h)
+
+	merged := make([]types.Neighbor, 0, k)
+	seen := make(map[string]struct{}, k)
+	for h.Len() > 0 && len(merged) < k {
+		c := h[0]
+		n := c.list[c.pos]
+		if _, dup := seen[n.ID]; !dup {
+			seen[n.ID] = struct{}{}
+			merged = append(merged, n)
+		}
+
+		c.pos++
+		if c.pos < len(c.list) {
+			heap.Fix(&h, 0)
+		} else {
+			heap.Pop(&h)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,45 @@
+package dispatcher
+
+import (
+	"reflect"
+	"testing"
+
+	"goflix/pkg/types"
+)
+
+func TestMergeTopK(t *testing.T) {
+	results := []Result{
+		{Neighbors: []types.Neighbor{{ID: "5", Similarity: 0.9}, {ID: "3", Similarity: 0.5}}},
+		// desordenado y con el 5 repetido con menor similitud
+		{Neighbors: []types.Neighbor{{ID: "10", Similarity: 0.5}, {ID: "5", Similarity: 0.7}, {ID: "2", Similarity: 0.5}}},
+		// los bloques con error no cuentan
+		{Error: "chunk perdido", Neighbors: []types.Neighbor{{ID: "1", Similarity: 1}}},
+		{},
+	}
+
+	tests := []struct {
+		name string
+		k    int
+		want []types.Neighbor
+	}{
+		// empates por ID numérico: 2 < 3 < 10
+		{"todos", 10, []types.Neighbor{{ID: "5", Similarity: 0.9}, {ID: "2", Similarity: 0.5}, {ID: "3", Similarity: 0.5}, {ID: "10", Similarity: 0.5}}},
+		{"truncado en un empate", 2, []types.Neighbor{{ID: "5", Similarity: 0.9}, {ID: "2", Similarity: 0.5}}},
+		{"k=0", 0, []types.Neighbor{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MergeTopK(results, tt.k); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("MergeTopK = %v, want %v", got, tt.want)
+			}
+			// el orden de llegada de los bloques no cambia el resultado
+			reversed := make([]Result, len(results))
+			for i, r := range results {
+				reversed[len(results)-1-i] = r
+			}
+			if got := MergeTopK(reversed, tt.k); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("MergeTopK en orden inverso = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package dispatcher
+
+// estimateWork devuelve el costo estimado de puntuar cada candidato contra el
+// usuario objetivo. El worker recorre los ratings del candidato para la norma
+// y el producto punto, así que el costo crece con la cantidad de ratings.
+func estimateWork(userIDs []int, userRatings map[int]map[int]float64) []int {
+	work := make([]int, len(userIDs))
+	for i, id := range userIDs {
+		work[i] = len(userRatings[id]) + 1
+	}
+	return work
+}
+
+// partitionByWork corta userIDs (ya ordenados) en numBlocks rangos contiguos
+// [start, end) de trabajo acumulado parecido. Todos los bloques reciben al
+// menos un candidato mientras queden suficientes. Los shards son iguales para
+// todos los workers: el rendimiento medido de cada uno solo lo usa el
+// scheduler LatencyAware al elegir a quién mandar cada shard.
+func partitionByWork(work []int, numBlocks int) [][2]int {
+	if numBlocks <= 0 || len(work) == 0 {
+		return nil
+	}
+	if numBlocks > len(work) {
+		numBlocks = len(work)
+	}
+
+	totalWork := 0
+	for _, w := range work {
+		totalWork += w
+	}
+
+	blocks := make([][2]int, 0, numBlocks)
+	start := 0
+	acc := 0
+	target := 0.0
+	for i := 0; i < numBlocks; i++ {
+		if i == numBlocks-1 {
+			blocks = append(blocks, [2]int{start, len(work)})
+			break
+		}
+
+		target += float64(totalWork) / float64(numBlocks)
+		end := start
+		// cada bloque se lleva al menos un candidato y deja uno por bloque restante
+		maxEnd := len(work) - (numBlocks - 1 - i)
+		for end < maxEnd && (end == start || float64(acc+work[end]) <= target) {
+			acc += work[end]
+			end++
+		}
+		blocks = append(blocks, [2]int{start, end})
+		start = end
+	}
+	return blocks
+}
@@ -0,0 +1,161 @@
+package dispatcher
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"goflix/pkg/types"
+)
+
+// minPredictionSupport es la cantidad mínima de vecinos que deben haber
+// calificado una película para predecirla; con un solo vecino la
+// predicción es solo una copia de su rating.
+const minPredictionSupport = 2
+
+// Progress describe el avance de un job de recomendación tras cada RESULT.
+type Progress struct {
+	Done        int
+	Total       int
+	Block       types.Block
+	Partial     bool               // el chunk venció su deadline en el worker
+	Predictions []types.Prediction // top-N con los vecinos recibidos hasta ahora
+}
+
+// Recommend ejecuta el pipeline completo de filtrado colaborativo basado en
+// usuarios: reparte los chunks entre los workers, junta los k vecinos más
+// similares y predice el rating de las películas que el usuario objetivo no
+// calificó. Devuelve las topN películas con mayor predicción. Si ctx vence
+// antes de recibir todos los chunks, predice con los vecinos que llegaron.
+func (d *Dispatcher) Recommend(ctx context.Context, opts JobOptions, userID int, k, topN int) ([]types.Prediction, error) {
+	return d.RecommendProgress(ctx, opts, userID, k, topN, nil)
+}
+
+// RecommendProgress es Recommend notificando a progress cada vez que llega
+// el RESULT de un chunk, con el top-N combinado hasta ese momento.
+func (d *Dispatcher) RecommendProgress(ctx context.Context, opts JobOptions, userID int, k, topN int, progress func(Progress)) ([]types.Prediction, error) {
+	ds := d.currentDataset()
+	if ds == nil {
+		return nil, fmt.Errorf("dataset no cargado")
+	}
+
+	resultsCh := make(chan Result, len(ds.shards))
+	count, err := d.run(ctx, opts, ds, []int{userID}, false, k, resultsCh)
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, nil
+	}
+
+	results := make([]Result, 0, count)
+	partial := false
+collect:
+	for i := 0; i < count; i++ {
+		select {
+		case res := <-resultsCh:
+			results = append(results, res)
+			partial = partial || res.Partial
+			if progress != nil {
+				progress(Progress{
+					Done:        len(results),
+					Total:       count,
+					Block:       res.BlockID,
+					Partial:     res.Partial,
+					Predictions: PredictMovies(ds.userRatings[userID], MergeTopK(results, k), ds.userRatings, topN),
+				})
+			}
+		case <-ctx.Done():
+			if len(results) == 0 {
+				return nil, ctx.Err()
+			}
+			partial = true
+			break collect
+		}
+	}
+
+	neighbors := MergeTopK(results, k)
+	if partial {
+		fmt.Println("Job", results[0].JobID, ": resultado parcial,", len(results), "de", count, "chunks")
+	}
+	fmt.Println("Job", results[0].JobID, ": vecinos combinados", len(neighbors))
+	return PredictMovies(ds.userRatings[userID], neighbors, ds.userRatings, topN), nil
+}
+
+// PredictMovies estima el rating del usuario objetivo para cada película que
+// calificaron sus vecinos y él no, con el promedio ponderado por similitud de
+// los ratings centrados en la media de cada vecino:
+//
+//	pred(u, i) = media(u) + Σ sim(u,v)·(r(v,i) − media(v)) / Σ |sim(u,v)|
+//
+// El resultado se ordena por predicción descendente (empates: más vecinos de
+// soporte primero, luego menor movieID) y se trunca a topN.
+func PredictMovies(targetRatings map[int]float64, neighbors []types.Neighbor, userRatings map[int]map[int]float64, topN int) []types.Prediction {
+	type acc struct {
+		num, den  float64
+		neighbors []string
+	}
+	accs := make(map[int]*acc)
+
+	for _, n := range neighbors {
+		vid, err := strconv.Atoi(n.ID)
+		if err != nil || n.Similarity <= 0 {
+			continue
+		}
+		ratings := userRatings[vid]
+		meanV := mean(ratings)
+		for movieID, r := range ratings {
+			if _, seen := targetRatings[movieID]; seen {
+				continue
+			}
+			a, ok := accs[movieID]
+			if !ok {
+				a = &acc{}
+				accs[movieID] = a
+			}
+			a.num += n.Similarity * (r - meanV)
+			a.den += math.Abs(n.Similarity)
+			a.neighbors = append(a.neighbors, n.ID)
+		}
+	}
+
+	meanU := mean(targetRatings)
+	preds := make([]types.Prediction, 0, len(accs))
+	for movieID, a := range accs {
+		if len(a.neighbors) < minPredictionSupport || a.den == 0 {
+			continue
+		}
+		preds = append(preds, types.Prediction{
+			MovieID:   movieID,
+			Score:     meanU + a.num/a.den,
+			Neighbors: a.neighbors,
+		})
+	}
+
+	sort.Slice(preds, func(i, j int) bool {
+		if preds[i].Score != preds[j].Score {
+			return preds[i].Score > preds[j].Score
+		}
+		if len(preds[i].Neighbors) != len(preds[j].Neighbors) {
+			return len(preds[i].Neighbors) > len(preds[j].Neighbors)
+		}
+		return preds[i].MovieID < preds[j].MovieID
+	})
+	if len(preds) > topN {
+		preds = preds[:topN]
+	}
+	return preds
+}
+
+func mean(ratings map[int]float64) float64 {
+	if len(ratings) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, r := range ratings {
+		sum += r
+	}
+	return sum / float64(len(ratings))
+}
@@ -0,0 +1,84 @@
+package dispatcher
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"goflix/pkg/types"
+)
+
+func TestPredictMovies(t *testing.T) {
+	// Valores calculados a mano. El objetivo tiene media 3.
+	//
+	//	vecino 10 (sim 0.8), media 4: películas 1, 3 y 4
+	//	vecino 20 (sim 0.4), media 4: películas 3, 4 y 5
+	//	vecino 30 (sim −0.5): se ignora por similitud no positiva
+	//	vecino 40 (sim 0.6), media 2: películas 5 y 6
+	//
+	//	película 3: 3 + (0.8·0 + 0.4·(−2)) / 1.2 = 7/3
+	//	película 4: 3 + (0.8·(−1) + 0.4·1) / 1.2 = 8/3
+	//	película 5: 3 + (0.4·1 + 0.6·(−1)) / 1.0 = 2.8
+	//	película 6: un solo vecino, sin soporte suficiente
+	//	película 1: ya la calificó el objetivo
+	target := map[int]float64{1: 4, 2: 2}
+	userRatings := map[int]map[int]float64{
+		10: {1: 5, 3: 4, 4: 3},
+		20: {3: 2, 4: 5, 5: 5},
+		30: {3: 1, 4: 1},
+		40: {5: 1, 6: 3},
+	}
+	neighbors := []types.Neighbor{
+		{ID: "10", Similarity: 0.8},
+		{ID: "40", Similarity: 0.6},
+		{ID: "20", Similarity: 0.4},
+		{ID: "30", Similarity: -0.5},
+	}
+
+	// empates: todas las desviaciones se cancelan y las predicciones valen
+	// la media del objetivo; gana el mayor soporte y luego el menor movieID
+	tieTarget := map[int]float64{1: 3}
+	tieRatings := map[int]map[int]float64{
+		1: {7: 4, 8: 4, 9: 1},
+		2: {7: 2, 8: 2, 9: 5},
+		3: {9: 3},
+	}
+	tieNeighbors := []types.Neighbor{{ID: "1", Similarity: 1}, {ID: "2", Similarity: 1}, {ID: "3", Similarity: 1}}
+
+	tests := []struct {
+		name        string
+		target      map[int]float64
+		neighbors   []types.Neighbor
+		userRatings map[int]map[int]float64
+		topN        int
+		want        []types.Prediction
+	}{
+		{"ponderado", target, neighbors, userRatings, 10, []types.Prediction{
+			{MovieID: 5, Score: 2.8, Neighbors: []string{"40", "20"}},
+			{MovieID: 4, Score: 8.0 / 3, Neighbors: []string{"10", "20"}},
+			{MovieID: 3, Score: 7.0 / 3, Neighbors: []string{"10", "20"}},
+		}},
+		{"topN", target, neighbors, userRatings, 1, []types.Prediction{
+			{MovieID: 5, Score: 2.8, Neighbors: []string{"40", "20"}},
+		}},
+		{"empates", tieTarget, tieNeighbors, tieRatings, 10, []types.Prediction{
+			{MovieID: 9, Score: 3, Neighbors: []string{"1", "2", "3"}},
+			{MovieID: 7, Score: 3, Neighbors: []string{"1", "2"}},
+			{MovieID: 8, Score: 3, Neighbors: []string{"1", "2"}},
+		}},
+		{"sin vecinos", target, nil, userRatings, 10, []types.Prediction{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := PredictMovies(tt.target, tt.neighbors, tt.userRatings, tt.topN)
+			if len(got) != len(tt.want) {
+				t.Fatalf("PredictMovies = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i].MovieID != tt.want[i].MovieID || math.Abs(got[i].Score-tt.want[i].Score) > 1e-9 || !reflect.DeepEqual(got[i].Neighbors, tt.want[i].Neighbors) {
+					t.Fatalf("predicción %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
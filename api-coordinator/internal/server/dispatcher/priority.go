@@ -0,0 +1,123 @@
+package dispatcher
+
+// Priority es la clase de un job. Las clases se atienden en orden estricto:
+// mientras haya chunks interactivos pendientes, ningún worker recibe chunks
+// de clases inferiores.
+type Priority int
+
+const (
+	PriorityInteractive Priority = iota // recomendaciones pedidas por HTTP
+	PriorityAdmin                       // reconstrucciones lanzadas por un administrador
+	PriorityBackground                  // precálculos por lotes
+	numPriorities
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityInteractive:
+		return "interactive"
+	case PriorityAdmin:
+		return "admin"
+	case PriorityBackground:
+		return "background"
+	default:
+		return "unknown"
+	}
+}
+
+// JobOptions define cómo compite un job por los workers.
+type JobOptions struct {
+	Priority Priority
+	Tenant   string  // usuario o tenant dueño del job
+	Weight   float64 // peso del tenant dentro de su clase (0 = 1)
+}
+
+// tenantQueue son los chunks pendientes de un tenant dentro de una clase.
+type tenantQueue struct {
+	chunks []*chunk
+	weight float64
+	vtime  float64 // trabajo servido / peso
+}
+
+// fairQueue guarda los chunks pendientes por clase y tenant. Dentro de una
+// clase reparte los workers con fair queuing ponderado: se atiende al tenant
+// con menor trabajo servido relativo a su peso.
+type fairQueue struct {
+	classes [numPriorities]map[string]*tenantQueue
+	clock   [numPriorities]float64 // vtime del último chunk servido por clase
+	size    int
+}
+
+func newFairQueue() *fairQueue {
+	q := &fairQueue{}
+	for i := range q.classes {
+		q.classes[i] = make(map[string]*tenantQueue)
+	}
+	return q
+}
+
+func (q *fairQueue) Len() int { return q.size }
+
+func (q *fairQueue) tenant(c *chunk) *tenantQueue {
+	opts := c.job.opts
+	class := q.classes[opts.Priority]
+	tq, ok := class[opts.Tenant]
+	if !ok {
+		weight := opts.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		// un tenant que vuelve no acumula crédito por el tiempo que estuvo inactivo
+		tq = &tenantQueue{weight: weight, vtime: q.clock[opts.Priority]}
+		class[opts.Tenant] = tq
+	}
+	return tq
+}
+
+// Push agrega un chunk al final de la cola de su tenant.
+func (q *fairQueue) Push(c *chunk) {
+	tq := q.tenant(c)
+	tq.chunks = append(tq.chunks, c)
+	q.size++
+}
+
+// PushFront devuelve un chunk al frente de la cola de su tenant, para
+// reintentos de chunks cuyo job ya está esperando. Todo chunk que vuelve ya
+// pasó por Pop: se le devuelve al tenant lo que se le cobró, porque el chunk
+// no llegó a servirse y los reintentos no deben restarle su parte.
+func (q *fairQueue) PushFront(c *chunk) {
+	tq := q.tenant(c)
+	tq.vtime -= float64(c.shard.work) / tq.weight
+	tq.chunks = append([]*chunk{c}, tq.chunks...)
+	q.size++
+}
+
+// Pop saca el siguiente chunk: la clase más prioritaria con trabajo y, dentro
+// de ella, el tenant con menor vtime (empates por nombre, para ser determinista).
+// El trabajo del chunk se le cobra al tenant; PushFront lo devuelve.
+func (q *fairQueue) Pop() *chunk {
+	for p := range q.classes {
+		class := q.classes[p]
+		var bestName string
+		var best *tenantQueue
+		for name, tq := range class {
+			if best == nil || tq.vtime < best.vtime || (tq.vtime == best.vtime && name < bestName) {
+				best, bestName = tq, name
+			}
+		}
+		if best == nil {
+			continue
+		}
+
+		c := best.chunks[0]
+		best.chunks = best.chunks[1:]
+		best.vtime += float64(c.shard.work) / best.weight
+		q.clock[p] = best.vtime
+		if len(best.chunks) == 0 {
+			delete(class, bestName)
+		}
+		q.size--
+		return c
+	}
+	return nil
+}
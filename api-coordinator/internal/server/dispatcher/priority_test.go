@@ -0,0 +1,132 @@
+package dispatcher
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// fakeChunk arma un chunk de 100 unidades de trabajo; label lo identifica en
+// el orden de salida.
+func fakeChunk(opts JobOptions, id int) *chunk {
+	return &chunk{
+		job:   &job{id: opts.Tenant, opts: opts},
+		shard: &shard{id: id, work: 100},
+	}
+}
+
+func label(c *chunk) string {
+	return fmt.Sprintf("%s%d", c.job.opts.Tenant, c.shard.id)
+}
+
+// pushN encola n chunks del tenant con ids 1..n.
+func pushN(q *fairQueue, opts JobOptions, n int) {
+	for i := 1; i <= n; i++ {
+		q.Push(fakeChunk(opts, i))
+	}
+}
+
+// popN saca hasta n chunks y devuelve sus etiquetas.
+func popN(q *fairQueue, n int) []string {
+	var out []string
+	for i := 0; i < n && q.Len() > 0; i++ {
+		out = append(out, label(q.Pop()))
+	}
+	return out
+}
+
+func TestFairQueue(t *testing.T) {
+	a := JobOptions{Priority: PriorityInteractive, Tenant: "a"}
+	b := JobOptions{Priority: PriorityInteractive, Tenant: "b"}
+
+	tests := []struct {
+		name string
+		run  func(q *fairQueue) []string
+		want []string
+	}{
+		{
+			name: "clases en orden estricto",
+			run: func(q *fairQueue) []string {
+				pushN(q, JobOptions{Priority: PriorityBackground, Tenant: "bg"}, 1)
+				pushN(q, JobOptions{Priority: PriorityAdmin, Tenant: "adm"}, 1)
+				pushN(q, a, 2)
+				return popN(q, 4)
+			},
+			want: []string{"a1", "a2", "adm1", "bg1"},
+		},
+		{
+			name: "fifo dentro del tenant",
+			run: func(q *fairQueue) []string {
+				pushN(q, a, 3)
+				return popN(q, 3)
+			},
+			want: []string{"a1", "a2", "a3"},
+		},
+		{
+			name: "mismo peso alterna",
+			run: func(q *fairQueue) []string {
+				pushN(q, a, 3)
+				pushN(q, b, 3)
+				return popN(q, 6)
+			},
+			want: []string{"a1", "b1", "a2", "b2", "a3", "b3"},
+		},
+		{
+			// b con peso 2 recibe el doble de chunks: vtime de a avanza 100
+			// por chunk y el de b 50
+			name: "ponderado",
+			run: func(q *fairQueue) []string {
+				pushN(q, a, 4)
+				pushN(q, JobOptions{Priority: PriorityInteractive, Tenant: "b", Weight: 2}, 4)
+				return popN(q, 8)
+			},
+			want: []string{"a1", "b1", "b2", "a2", "b3", "b4", "a3", "a4"},
+		},
+		{
+			// un tenant que llega tarde arranca en el reloj de la clase y no
+			// se lleva todos los workers por el tiempo que estuvo inactivo
+			name: "tenant nuevo sin crédito acumulado",
+			run: func(q *fairQueue) []string {
+				pushN(q, a, 4)
+				out := popN(q, 3)
+				pushN(q, b, 2)
+				return append(out, popN(q, 3)...)
+			},
+			want: []string{"a1", "a2", "a3", "a4", "b1", "b2"},
+		},
+		{
+			// un chunk que vuelve sin servirse no se le cobra al tenant
+			name: "PushFront devuelve el cobro",
+			run: func(q *fairQueue) []string {
+				pushN(q, a, 2)
+				pushN(q, b, 2)
+				for i := 0; i < 3; i++ {
+					q.PushFront(q.Pop())
+				}
+				return popN(q, 4)
+			},
+			want: []string{"a1", "b1", "a2", "b2"},
+		},
+		{
+			name: "PushFront al frente del tenant",
+			run: func(q *fairQueue) []string {
+				pushN(q, a, 2)
+				first := q.Pop()
+				q.PushFront(first)
+				return popN(q, 2)
+			},
+			want: []string{"a1", "a2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newFairQueue()
+			if got := tt.run(q); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("orden = %v, want %v", got, tt.want)
+			}
+			if q.Len() != 0 || q.Pop() != nil {
+				t.Fatalf("quedaron %d chunks en la cola", q.Len())
+			}
+		})
+	}
+}
@@ -0,0 +1,431 @@
+package dispatcher
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"goflix/pkg/types"
+)
+
+// maxChunkAttempts es la cantidad de envíos de un chunk antes de darlo por perdido.
+const maxChunkAttempts = 3
+
+// latencyTimeoutFactor multiplica la latencia medida de un worker para no
+// reencolar chunks que simplemente son lentos en él.
+const latencyTimeoutFactor = 4
+
+// deadlineMargin se descuenta del deadline del job al armar el TASK, para que
+// el resultado parcial del worker llegue antes de que venza el pedido.
+const deadlineMargin = 250 * time.Millisecond
+
+// job agrupa los chunks de una misma solicitud de recomendación.
+type job struct {
+	ctx       context.Context
+	id        string
+	opts      JobOptions
+	data      *dataset
+	topN      int
+	targetIDs []int
+	batch     bool // un TASK con todos los objetivos y vecinos por objetivo
+	scoring   types.Scoring
+	algorithm string // tipo de TASK (types.AlgoUserNeighbors, ...)
+	resultsCh chan<- Result
+}
+
+// deliver entrega el resultado de un chunk a quien lanzó el job.
+func (j *job) deliver(res Result) {
+	select {
+	case j.resultsCh <- res:
+	case <-j.ctx.Done():
+	}
+}
+
+// chunk es la parte de un job que corresponde a un shard del dataset.
+type chunk struct {
+	job      *job
+	shard    *shard
+	attempts int
+	tooLarge map[string]bool // workers que lo rechazaron por memoria
+}
+
+// memory estima la memoria que el chunk ocupa en el worker: los candidatos
+// del shard y los ratings de los objetivos.
+func (c *chunk) memory() int64 {
+	ratings := c.shard.work
+	for _, id := range c.job.targetIDs {
+		ratings += len(c.job.data.userRatings[id])
+	}
+	return types.RatingsMemory(ratings)
+}
+
+// fits indica si el chunk se puede enviar a w sin superar su presupuesto.
+func (c *chunk) fits(w WorkerInfo) bool {
+	if c.tooLarge[w.ID] {
+		return false
+	}
+	return w.MemoryBudget <= 0 || c.memory() <= w.MemoryBudget
+}
+
+// unplaceable explica por qué ningún worker conectado puede tomar el chunk, o
+// devuelve "" si alguno puede. En ese caso esperar un PULL no sirve de nada.
+func (c *chunk) unplaceable(workers []WorkerInfo) string {
+	supported := false
+	for _, w := range workers {
+		if !types.SupportsAlgorithm(w.Algorithms, c.job.algorithm) {
+			continue
+		}
+		if c.fits(w) {
+			return ""
+		}
+		supported = true
+	}
+	if !supported {
+		algorithm := c.job.algorithm
+		if algorithm == "" {
+			algorithm = types.AlgoUserNeighbors
+		}
+		return fmt.Sprintf("ningún worker conectado soporta el algoritmo %q", algorithm)
+	}
+	return "chunk demasiado grande para la memoria de los workers"
+}
+
+// task arma el TASK del chunk. Solo viajan los ratings de los usuarios
+// objetivo; los candidatos se referencian por el hash del shard, que el
+// worker ya tiene en caché gracias a LOAD_SHARD.
+func (c *chunk) task() types.Task {
+	task := types.Task{
+		JobID:     c.job.id,
+		BlockID:   c.shard.block,
+		K:         c.job.topN,
+		Epoch:     c.job.data.epoch,
+		ShardID:   c.shard.id,
+		ShardHash: c.shard.hash,
+		Scoring:   c.job.scoring,
+		Algorithm: c.job.algorithm,
+	}
+	if deadline, ok := c.job.ctx.Deadline(); ok {
+		task.Deadline = deadline.Add(-deadlineMargin).UnixMilli()
+	}
+	ratings := c.job.data.userRatings
+	if !c.job.batch {
+		task.TargetID = c.job.targetIDs[0]
+		task.TargetRatings = ratings[task.TargetID]
+		return task
+	}
+	task.Targets = make([]types.Target, 0, len(c.job.targetIDs))
+	for _, id := range c.job.targetIDs {
+		task.Targets = append(task.Targets, types.Target{UserID: id, Ratings: ratings[id]})
+	}
+	return task
+}
+
+// assignment registra un chunk en vuelo y el worker que lo procesa.
+type assignment struct {
+	chunk    *chunk
+	workerID string
+	sentAt   time.Time
+	task     types.Task // TASK enviado, para grabarlo junto al RESULT
+}
+
+func taskKey(jobID string, block types.Block) string {
+	return fmt.Sprintf("%s:%d-%d", jobID, block.StartID, block.EndID)
+}
+
+// handlePull marca al worker como disponible y le asigna trabajo si hay.
+func (d *Dispatcher) handlePull(workerID string) {
+	d.setWorkerState(workerID, types.WorkerIdle)
+	d.fitDataset()
+
+	d.mu.Lock()
+	queued := false
+	for _, id := range d.ready {
+		if id == workerID {
+			queued = true
+			break
+		}
+	}
+	if !queued {
+		d.ready = append(d.ready, workerID)
+	}
+	d.mu.Unlock()
+
+	d.schedule()
+}
+
+// handleResult entrega el RESULT al job correspondiente. Si el chunk ya fue
+// entregado (por ejemplo, un worker lento que respondió después de la
+// reasignación) el resultado se descarta.
+func (d *Dispatcher) handleResult(workerID string, result Result) {
+	key := taskKey(result.JobID, result.BlockID)
+	d.mu.Lock()
+	a, ok := d.inflight[key]
+	if ok {
+		delete(d.inflight, key)
+	}
+	recorder := d.recorder
+	d.mu.Unlock()
+
+	if !ok {
+		fmt.Println("RESULT descartado, chunk desconocido o ya entregado:", key)
+		return
+	}
+	if result.Error == types.ErrShardMissing {
+		// el worker perdió el shard (por ejemplo, se reinició): reenviarlo.
+		// Cuenta como intento, por si el worker no logra decodificarlo nunca
+		fmt.Println("Worker", workerID, "no tiene el shard", a.chunk.shard.id, ", reencolando")
+		d.mu.Lock()
+		delete(d.loaded[workerID], a.chunk.shard.hash)
+		d.mu.Unlock()
+		d.retryChunk(a.chunk, result)
+		return
+	}
+	if result.Error == types.ErrTaskInvalid {
+		// el worker no pudo decodificar el TASK: reenviarlo sin esperar al timeout
+		fmt.Println("Worker", workerID, "no pudo decodificar el chunk", key, ", reencolando")
+		d.retryChunk(a.chunk, result)
+		return
+	}
+	if result.Error == types.ErrTaskTooLarge {
+		// reintentable: probar con otro worker, que quizás tenga más memoria.
+		// No cuenta como intento: schedule devuelve el error cuando ya lo
+		// rechazaron todos los workers donde podía entrar
+		c := a.chunk
+		fmt.Println("Worker", workerID, "rechazó el chunk", key, "por memoria, reencolando")
+		d.mu.Lock()
+		if c.tooLarge == nil {
+			c.tooLarge = make(map[string]bool)
+		}
+		c.tooLarge[workerID] = true
+		d.queue.PushFront(c)
+		d.mu.Unlock()
+		d.schedule()
+		return
+	}
+	// solo se graban los RESULT finales: los errores reintentables de arriba
+	// no se pueden reproducir offline
+	if recorder != nil {
+		recorder.record(workerID, a, result)
+	}
+	if a.workerID == workerID {
+		d.recordThroughput(workerID, a.chunk.shard.work, time.Since(a.sentAt))
+	}
+	a.chunk.job.deliver(result)
+}
+
+// retryChunk reencola c tras un error reintentable del worker. Al agotar
+// maxChunkAttempts entrega result al job como error final.
+func (d *Dispatcher) retryChunk(c *chunk, result Result) {
+	c.attempts++
+	if c.attempts >= maxChunkAttempts {
+		fmt.Println("Chunk", taskKey(c.job.id, c.shard.block), "perdido tras", c.attempts, "intentos, último error:", result.Error)
+		result.Error = "chunk perdido tras agotar reintentos"
+		c.job.deliver(result)
+		return
+	}
+	d.mu.Lock()
+	d.queue.PushFront(c)
+	d.mu.Unlock()
+	d.schedule()
+}
+
+// schedule reparte los chunks pendientes entre los workers que pidieron
+// trabajo: la cola de prioridades decide qué chunks salen y el Scheduler
+// configurado a qué worker va cada uno.
+func (d *Dispatcher) schedule() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for len(d.ready) > 0 && d.queue.Len() > 0 {
+		workers := d.workerInfos()
+		chunks := make([]*chunk, 0, len(d.ready))
+		for len(chunks) < len(d.ready) && d.queue.Len() > 0 {
+			c := d.queue.Pop()
+			if c.job.ctx.Err() != nil {
+				// nadie espera ya este job
+				continue
+			}
+			if reason := c.unplaceable(workers); reason != "" {
+				// ningún worker conectado lo acepta: devolver el error en vez
+				// de reencolarlo para siempre
+				fmt.Println("Chunk", taskKey(c.job.id, c.shard.block), "descartado:", reason)
+				go c.job.deliver(Result{
+					JobID:   c.job.id,
+					BlockID: c.shard.block,
+					Error:   reason,
+				})
+				continue
+			}
+			chunks = append(chunks, c)
+		}
+		if len(chunks) == 0 {
+			return
+		}
+
+		blocks := make([]BlockInfo, len(chunks))
+		for i, c := range chunks {
+			blocks[i] = BlockInfo{ShardID: c.shard.id, Work: c.shard.work}
+		}
+		picks := d.scheduler.Select(workers, blocks)
+
+		assigned := make(map[string]bool, len(chunks))
+		// en orden inverso para que PushFront conserve el orden original
+		for i := len(chunks) - 1; i >= 0; i-- {
+			c := chunks[i]
+			if picks[i] < 0 || !workers[picks[i]].Ready || assigned[workers[picks[i]].ID] || !types.SupportsAlgorithm(workers[picks[i]].Algorithms, c.job.algorithm) || !c.fits(workers[picks[i]]) {
+				d.queue.PushFront(c)
+				continue
+			}
+			workerID := workers[picks[i]].ID
+			assigned[workerID] = true
+
+			if err := d.ensureShard(workerID, c.job.data, c.shard); err != nil {
+				fmt.Println("Error enviando shard a worker", workerID, ":", err)
+				d.queue.PushFront(c)
+				continue
+			}
+			task := c.task()
+			if err := d.DispatchTask(workerID, task); err != nil {
+				fmt.Println("Error dispatching task to worker", workerID, ":", err)
+				d.queue.PushFront(c)
+				continue
+			}
+			d.inflight[taskKey(c.job.id, c.shard.block)] = &assignment{
+				chunk:    c,
+				workerID: workerID,
+				sentAt:   time.Now(),
+				task:     task,
+			}
+			d.setWorkerState(workerID, types.WorkerBusy)
+		}
+		if len(assigned) == 0 {
+			// el scheduler no eligió a nadie: esperar al próximo PULL
+			return
+		}
+
+		// los workers elegidos ya no están disponibles, aunque el envío fallara
+		ready := d.ready[:0]
+		for _, id := range d.ready {
+			if !assigned[id] {
+				ready = append(ready, id)
+			}
+		}
+		d.ready = ready
+	}
+}
+
+// workerInfos arma la vista de los workers conectados para el Scheduler,
+// ordenada por ID. Debe llamarse con d.mu tomado.
+func (d *Dispatcher) workerInfos() []WorkerInfo {
+	ready := make(map[string]bool, len(d.ready))
+	for _, id := range d.ready {
+		ready[id] = true
+	}
+	inflight := make(map[string]int)
+	for _, a := range d.inflight {
+		inflight[a.workerID]++
+	}
+
+	d.server.Mu.RLock()
+	workers := make([]WorkerInfo, 0, len(d.server.Workers))
+	for id, w := range d.server.Workers {
+		info := WorkerInfo{
+			ID:           id,
+			Ready:        ready[id],
+			Concurrency:  w.Concurrency,
+			Inflight:     inflight[id],
+			Algorithms:   w.Algorithms,
+			MemoryBudget: w.MemoryBudget,
+		}
+		if st, ok := d.stats[id]; ok {
+			info.Throughput = st.Throughput
+			info.Latency = st.Latency
+		}
+		workers = append(workers, info)
+	}
+	d.server.Mu.RUnlock()
+
+	sort.Slice(workers, func(i, j int) bool { return workers[i].ID < workers[j].ID })
+	return workers
+}
+
+// reapLostChunks reencola los chunks cuyo worker se desconectó o no respondió
+// a tiempo. Como los chunks son pequeños, rehacer uno cuesta poco.
+func (d *Dispatcher) reapLostChunks() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		d.server.Mu.RLock()
+		connected := make(map[string]struct{}, len(d.server.Workers))
+		for id := range d.server.Workers {
+			connected[id] = struct{}{}
+		}
+		d.server.Mu.RUnlock()
+
+		d.mu.Lock()
+		ready := d.ready[:0]
+		for _, id := range d.ready {
+			if _, ok := connected[id]; ok {
+				ready = append(ready, id)
+			}
+		}
+		d.ready = ready
+		for id := range d.loaded {
+			if _, ok := connected[id]; !ok {
+				delete(d.loaded, id)
+			}
+		}
+
+		requeued := false
+		for key, a := range d.inflight {
+			_, alive := connected[a.workerID]
+			if alive && time.Since(a.sentAt) < d.chunkTimeout(a.workerID) {
+				continue
+			}
+			delete(d.inflight, key)
+
+			c := a.chunk
+			c.attempts++
+			if c.attempts >= maxChunkAttempts {
+				fmt.Println("Chunk perdido tras", c.attempts, "intentos:", key)
+				go c.job.deliver(Result{
+					JobID:   c.job.id,
+					BlockID: c.shard.block,
+					Error:   "chunk perdido tras agotar reintentos",
+				})
+				continue
+			}
+			fmt.Println("Reencolando chunk", key, "del worker", a.workerID)
+			d.queue.PushFront(c)
+			requeued = true
+		}
+		d.mu.Unlock()
+
+		if requeued {
+			d.schedule()
+		}
+	}
+}
+
+// chunkTimeout es la espera máxima por el RESULT de un chunk de workerID:
+// d.timeouts, o latencyTimeoutFactor veces la duración habitual de sus
+// chunks si ese worker es más lento. Debe llamarse con d.mu tomado.
+func (d *Dispatcher) chunkTimeout(workerID string) time.Duration {
+	timeout := d.timeouts
+	if st, ok := d.stats[workerID]; ok {
+		if slow := latencyTimeoutFactor * st.Latency; slow > timeout {
+			timeout = slow
+		}
+	}
+	return timeout
+}
+
+func (d *Dispatcher) setWorkerState(workerID string, state types.WorkerState) {
+	d.server.Mu.Lock()
+	if w, ok := d.server.Workers[workerID]; ok {
+		w.State = state
+	}
+	d.server.Mu.Unlock()
+}
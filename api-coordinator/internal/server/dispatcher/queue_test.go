@@ -0,0 +1,120 @@
+package dispatcher
+
+import (
+	"context"
+	"testing"
+
+	"goflix/pkg/types"
+)
+
+// ratingsOf arma usuarios 1..len(sizes) con sizes[i] ratings cada uno.
+func ratingsOf(sizes ...int) (map[int]map[int]float64, []int) {
+	ratings := make(map[int]map[int]float64, len(sizes))
+	ids := make([]int, len(sizes))
+	for i, n := range sizes {
+		id := i + 1
+		ids[i] = id
+		ratings[id] = make(map[int]float64, n)
+		for m := 0; m < n; m++ {
+			ratings[id][m] = 3
+		}
+	}
+	return ratings, ids
+}
+
+func TestChunkUnplaceable(t *testing.T) {
+	ratings, ids := ratingsOf(10, 10, 10, 10)
+	ds := newDataset(ratings, ids, 1)
+	c := &chunk{
+		job:   &job{data: ds, targetIDs: []int{1}, algorithm: types.AlgoUserNeighbors},
+		shard: ds.shards[0],
+	}
+	need := c.memory()
+
+	small := WorkerInfo{ID: "w1", MemoryBudget: need - 1}
+	big := WorkerInfo{ID: "w2", MemoryBudget: need}
+	unlimited := WorkerInfo{ID: "w3"}
+	other := WorkerInfo{ID: "w4", Algorithms: []string{"otro"}}
+
+	const tooLarge = "chunk demasiado grande para la memoria de los workers"
+	const unsupported = `ningún worker conectado soporta el algoritmo "user-neighbors"`
+	tests := []struct {
+		name     string
+		workers  []WorkerInfo
+		tooLarge map[string]bool
+		want     string
+	}{
+		{"sin workers", nil, nil, unsupported},
+		{"presupuesto chico", []WorkerInfo{small}, nil, tooLarge},
+		{"entra justo", []WorkerInfo{small, big}, nil, ""},
+		{"sin límite", []WorkerInfo{unlimited}, nil, ""},
+		{"algoritmo no soportado", []WorkerInfo{other}, nil, unsupported},
+		{"solo lo soporta uno chico", []WorkerInfo{other, small}, nil, tooLarge},
+		{"todos lo rechazaron", []WorkerInfo{big, unlimited}, map[string]bool{"w2": true, "w3": true}, tooLarge},
+		{"queda uno", []WorkerInfo{big, unlimited}, map[string]bool{"w2": true}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.tooLarge = tt.tooLarge
+			if got := c.unplaceable(tt.workers); got != tt.want {
+				t.Fatalf("unplaceable = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFitBatch(t *testing.T) {
+	ratings, ids := ratingsOf(10, 10, 10, 10, 10)
+	ds := newDataset(ratings, ids, 1)
+	perUser := types.RatingsMemory(10)
+
+	tests := []struct {
+		name      string
+		batchSize int
+		budget    int64
+		want      int
+	}{
+		{"sin presupuesto", 3, 0, 3},
+		{"batch mayor que los usuarios", 10, 0, 5},
+		// la mitad del presupuesto alcanza para dos objetivos
+		{"recortado", 5, 4 * perUser, 2},
+		{"entra entero", 3, 6 * perUser, 3},
+		// ni uno entra: se manda igual y schedule devuelve el error
+		{"siempre al menos uno", 5, perUser, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fitBatch(ds, ids, tt.batchSize, tt.budget); got != tt.want {
+				t.Fatalf("fitBatch = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryChunkGivesUp(t *testing.T) {
+	// sin workers disponibles schedule no hace nada: el chunk queda en la cola
+	d := &Dispatcher{queue: newFairQueue()}
+	results := make(chan Result, 1)
+	c := fakeChunk(JobOptions{Tenant: "a"}, 1)
+	c.job.ctx = context.Background()
+	c.job.resultsCh = results
+
+	for i := 1; i < maxChunkAttempts; i++ {
+		d.retryChunk(c, Result{Error: types.ErrShardMissing})
+		if d.queue.Len() != 1 || d.queue.Pop() != c {
+			t.Fatalf("intento %d: el chunk no volvió a la cola", i)
+		}
+	}
+	d.retryChunk(c, Result{Error: types.ErrShardMissing})
+	if d.queue.Len() != 0 {
+		t.Fatal("el chunk volvió a la cola tras agotar los intentos")
+	}
+	select {
+	case res := <-results:
+		if res.Error == "" || res.Error == types.ErrShardMissing {
+			t.Fatalf("Error = %q, want un error final", res.Error)
+		}
+	default:
+		t.Fatal("no se entregó el error al job")
+	}
+}
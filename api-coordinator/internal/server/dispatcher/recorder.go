@@ -0,0 +1,102 @@
+package dispatcher
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"goflix/pkg/record"
+	"goflix/pkg/types"
+)
+
+// recorderQueue es cuántas grabaciones pueden esperar a escribirse. Si se
+// llena se descartan, para que escribir a disco no frene al dispatcher.
+const recorderQueue = 256
+
+// Recorder graba cada par TASK/RESULT en un directorio con el formato de
+// goflix/pkg/record, para reproducirlo después con "worker replay". Los
+// shards se escriben una sola vez por hash.
+type Recorder struct {
+	dir    string
+	ch     chan recording
+	mu     sync.Mutex
+	shards map[string]bool // hashes ya encolados para escribir
+}
+
+type recording struct {
+	task  record.Task
+	shard *types.ShardData // nil si ya se escribió
+}
+
+// NewRecorder crea el directorio de grabación y arranca la goroutine que
+// escribe los archivos.
+func NewRecorder(dir string) (*Recorder, error) {
+	for _, sub := range []string{record.ShardsDir, record.TasksDir} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, err
+		}
+	}
+	r := &Recorder{
+		dir:    dir,
+		ch:     make(chan recording, recorderQueue),
+		shards: make(map[string]bool),
+	}
+	go r.loop()
+	return r, nil
+}
+
+// SetRecorder activa la grabación de TASK/RESULT (nil la desactiva).
+func (d *Dispatcher) SetRecorder(r *Recorder) {
+	d.mu.Lock()
+	d.recorder = r
+	d.mu.Unlock()
+}
+
+// record encola la grabación del RESULT de a.
+func (r *Recorder) record(workerID string, a *assignment, result types.Result) {
+	rec := recording{task: record.Task{
+		WorkerID:   workerID,
+		SentAt:     a.sentAt,
+		DurationMs: time.Since(a.sentAt).Milliseconds(),
+		Task:       a.task,
+		Result:     result,
+	}}
+
+	r.mu.Lock()
+	hash := a.chunk.shard.hash
+	if a.task.ShardHash != "" && !r.shards[hash] {
+		r.shards[hash] = true
+		data := a.chunk.job.data.shardData(a.chunk.shard)
+		rec.shard = &data
+	}
+	r.mu.Unlock()
+
+	select {
+	case r.ch <- rec:
+	default:
+		log.Printf("[DISPATCHER] Cola de grabación llena, se descarta %s", taskKey(a.task.JobID, a.task.BlockID))
+		if rec.shard != nil {
+			r.mu.Lock()
+			delete(r.shards, hash)
+			r.mu.Unlock()
+		}
+	}
+}
+
+func (r *Recorder) loop() {
+	for rec := range r.ch {
+		if rec.shard != nil {
+			if err := record.Write(record.ShardPath(r.dir, rec.shard.Hash), rec.shard); err != nil {
+				log.Printf("[DISPATCHER] Error grabando shard %s: %v", rec.shard.Hash, err)
+				r.mu.Lock()
+				delete(r.shards, rec.shard.Hash)
+				r.mu.Unlock()
+			}
+		}
+		if err := record.Write(record.TaskPath(r.dir, &rec.task), &rec.task); err != nil {
+			log.Printf("[DISPATCHER] Error grabando TASK %s: %v", rec.task.Task.JobID, err)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package dispatcher
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WorkerInfo es lo que ve una política de scheduling de cada worker conectado.
+type WorkerInfo struct {
+	ID           string
+	Ready        bool // pidió trabajo (PULL) y puede recibir un bloque
+	Concurrency  int
+	Inflight     int           // chunks enviados sin RESULT todavía
+	Throughput   float64       // trabajo por milisegundo (0 = sin historial)
+	Latency      time.Duration // duración promedio de sus chunks (0 = sin historial)
+	Algorithms   []string      // tipos de TASK que anunció en el HELLO
+	MemoryBudget int64         // bytes por tarea (0 = sin límite)
+}
+
+// BlockInfo describe un bloque pendiente de asignar.
+type BlockInfo struct {
+	ShardID int
+	Work    int
+}
+
+// Scheduler decide qué worker procesa cada bloque. Select recibe todos los
+// workers conectados (ordenados por ID) y los bloques a repartir, y devuelve
+// para cada bloque el índice del worker elegido o -1 para dejarlo en cola.
+// Solo puede elegir workers con Ready y a cada uno a lo sumo una vez.
+type Scheduler interface {
+	Name() string
+	Select(workers []WorkerInfo, blocks []BlockInfo) []int
+}
+
+// NewScheduler construye la política con el nombre dado:
+// "round-robin", "least-loaded", "latency-aware" o "consistent-hash".
+func NewScheduler(name string) (Scheduler, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "round-robin", "rr":
+		return &RoundRobin{}, nil
+	case "least-loaded":
+		return LeastLoaded{}, nil
+	case "latency-aware", "latency":
+		return LatencyAware{}, nil
+	case "consistent-hash", "hash":
+		return ConsistentHash{Replicas: defaultHashReplicas}, nil
+	default:
+		return nil, fmt.Errorf("scheduler desconocido: %q", name)
+	}
+}
+
+func unassigned(n int) []int {
+	picks := make([]int, n)
+	for i := range picks {
+		picks[i] = -1
+	}
+	return picks
+}
+
+// readyWorkers devuelve los índices de los workers disponibles.
+func readyWorkers(workers []WorkerInfo) []int {
+	ready := make([]int, 0, len(workers))
+	for i, w := range workers {
+		if w.Ready {
+			ready = append(ready, i)
+		}
+	}
+	return ready
+}
+
+// RoundRobin reparte los bloques rotando sobre los workers, continuando
+// donde quedó la llamada anterior.
+type RoundRobin struct {
+	mu   sync.Mutex
+	last string // último worker asignado
+}
+
+func (r *RoundRobin) Name() string { return "round-robin" }
+
+func (r *RoundRobin) Select(workers []WorkerInfo, blocks []BlockInfo) []int {
+	picks := unassigned(len(blocks))
+	ready := readyWorkers(workers)
+	if len(ready) == 0 {
+		return picks
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// primer worker disponible con ID mayor al último asignado
+	start := sort.Search(len(ready), func(i int) bool { return workers[ready[i]].ID > r.last })
+	for i := range blocks {
+		if i == len(ready) {
+			break
+		}
+		idx := ready[(start+i)%len(ready)]
+		picks[i] = idx
+		r.last = workers[idx].ID
+	}
+	return picks
+}
+
+// LeastLoaded asigna primero a los workers con menos chunks en vuelo por
+// goroutine disponible.
+type LeastLoaded struct{}
+
+func (LeastLoaded) Name() string { return "least-loaded" }
+
+func (LeastLoaded) Select(workers []WorkerInfo, blocks []BlockInfo) []int {
+	load := func(w WorkerInfo) float64 {
+		c := w.Concurrency
+		if c <= 0 {
+			c = 1
+		}
+		return float64(w.Inflight) / float64(c)
+	}
+
+	order := readyWorkers(workers)
+	sort.SliceStable(order, func(a, b int) bool {
+		return load(workers[order[a]]) < load(workers[order[b]])
+	})
+
+	picks := unassigned(len(blocks))
+	for i := range blocks {
+		if i == len(order) {
+			break
+		}
+		picks[i] = order[i]
+	}
+	return picks
+}
+
+// LatencyAware minimiza el tiempo esperado de cada bloque: los bloques con
+// más trabajo van a los workers con mayor throughput medido. Los workers sin
+// historial se asumen con el throughput promedio de los conocidos.
+type LatencyAware struct{}
+
+func (LatencyAware) Name() string { return "latency-aware" }
+
+func (LatencyAware) Select(workers []WorkerInfo, blocks []BlockInfo) []int {
+	avg, known := 0.0, 0
+	for _, w := range workers {
+		if w.Throughput > 0 {
+			avg += w.Throughput
+			known++
+		}
+	}
+	if known > 0 {
+		avg /= float64(known)
+	} else {
+		avg = 1
+	}
+	speed := func(w WorkerInfo) float64 {
+		if w.Throughput > 0 {
+			return w.Throughput
+		}
+		return avg
+	}
+
+	byWork := make([]int, len(blocks))
+	for i := range byWork {
+		byWork[i] = i
+	}
+	sort.SliceStable(byWork, func(a, b int) bool { return blocks[byWork[a]].Work > blocks[byWork[b]].Work })
+
+	bySpeed := readyWorkers(workers)
+	sort.SliceStable(bySpeed, func(a, b int) bool { return speed(workers[bySpeed[a]]) > speed(workers[bySpeed[b]]) })
+
+	picks := unassigned(len(blocks))
+	for i, b := range byWork {
+		if i == len(bySpeed) {
+			break
+		}
+		picks[b] = bySpeed[i]
+	}
+	return picks
+}
+
+const defaultHashReplicas = 64
+
+// ConsistentHash asigna cada shard al worker que le corresponde en un anillo
+// de hashing consistente, así un mismo shard vuelve al mismo worker (que ya lo
+// tiene cargado) y al entrar o salir un worker solo se mueven sus shards. El
+// anillo incluye a todos los workers conectados; si el dueño está ocupado se
+// usa el siguiente worker disponible del anillo.
+type ConsistentHash struct {
+	Replicas int // nodos virtuales por worker
+}
+
+func (ConsistentHash) Name() string { return "consistent-hash" }
+
+func (c ConsistentHash) Select(workers []WorkerInfo, blocks []BlockInfo) []int {
+	picks := unassigned(len(blocks))
+	if len(workers) == 0 {
+		return picks
+	}
+	replicas := c.Replicas
+	if replicas <= 0 {
+		replicas = defaultHashReplicas
+	}
+
+	type point struct {
+		hash   uint32
+		worker int
+	}
+	ring := make([]point, 0, len(workers)*replicas)
+	for i, w := range workers {
+		for r := 0; r < replicas; r++ {
+			ring = append(ring, point{hash: hash32(w.ID + "#" + strconv.Itoa(r)), worker: i})
+		}
+	}
+	sort.Slice(ring, func(a, b int) bool {
+		if ring[a].hash != ring[b].hash {
+			return ring[a].hash < ring[b].hash
+		}
+		return ring[a].worker < ring[b].worker
+	})
+
+	used := make([]bool, len(workers))
+	free := len(readyWorkers(workers))
+	for i, b := range blocks {
+		if free == 0 {
+			break
+		}
+		h := hash32("shard#" + strconv.Itoa(b.ShardID))
+		pos := sort.Search(len(ring), func(k int) bool { return ring[k].hash >= h })
+		for step := 0; step < len(ring); step++ {
+			p := ring[(pos+step)%len(ring)]
+			if workers[p.worker].Ready && !used[p.worker] {
+				used[p.worker] = true
+				free--
+				picks[i] = p.worker
+				break
+			}
+		}
+	}
+	return picks
+}
+
+// hash32 es FNV-1a seguido del finalizador de murmur3. FNV solo no alcanza:
+// las claves del anillo difieren en el último carácter ("w1#0", "w1#1", ...)
+// y sus hashes quedan agrupados, con lo que unos pocos workers se llevaban
+// casi todos los shards.
+func hash32(s string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(s))
+	x := h.Sum32()
+	x ^= x >> 16
+	x *= 0x85ebca6b
+	x ^= x >> 13
+	x *= 0xc2b2ae35
+	x ^= x >> 16
+	return x
+}
@@ -0,0 +1,293 @@
+package dispatcher
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeWorkers arma workers ordenados por ID, todos disponibles.
+func fakeWorkers(ids ...string) []WorkerInfo {
+	workers := make([]WorkerInfo, len(ids))
+	for i, id := range ids {
+		workers[i] = WorkerInfo{ID: id, Ready: true, Concurrency: 1}
+	}
+	return workers
+}
+
+func fakeBlocks(n int) []BlockInfo {
+	blocks := make([]BlockInfo, n)
+	for i := range blocks {
+		blocks[i] = BlockInfo{ShardID: i, Work: 100}
+	}
+	return blocks
+}
+
+func pickedIDs(workers []WorkerInfo, picks []int) []string {
+	ids := make([]string, len(picks))
+	for i, p := range picks {
+		if p >= 0 {
+			ids[i] = workers[p].ID
+		}
+	}
+	return ids
+}
+
+// checkPicks verifica el contrato de Select: un pick por bloque, solo
+// workers Ready, cada uno a lo sumo una vez y tantos bloques asignados como
+// workers disponibles haya.
+func checkPicks(t *testing.T, workers []WorkerInfo, blocks []BlockInfo, picks []int) {
+	t.Helper()
+	if len(picks) != len(blocks) {
+		t.Fatalf("picks = %d, want %d", len(picks), len(blocks))
+	}
+	used := make(map[int]bool)
+	assigned := 0
+	for i, p := range picks {
+		if p == -1 {
+			continue
+		}
+		if p < 0 || p >= len(workers) {
+			t.Fatalf("bloque %d: índice de worker inválido %d", i, p)
+		}
+		if !workers[p].Ready {
+			t.Errorf("bloque %d asignado a %s, que no está Ready", i, workers[p].ID)
+		}
+		if used[p] {
+			t.Errorf("worker %s recibió más de un bloque", workers[p].ID)
+		}
+		used[p] = true
+		assigned++
+	}
+	want := len(readyWorkers(workers))
+	if len(blocks) < want {
+		want = len(blocks)
+	}
+	if assigned != want {
+		t.Errorf("bloques asignados = %d, want %d", assigned, want)
+	}
+}
+
+func TestSchedulersOneBlockPerReadyWorker(t *testing.T) {
+	workers := fakeWorkers("w1", "w2", "w3", "w4", "w5")
+	workers[1].Ready = false
+	workers[3].Ready = false
+	workers[0].Throughput = 2
+	workers[2].Inflight = 3
+
+	for _, name := range []string{"round-robin", "least-loaded", "latency-aware", "consistent-hash"} {
+		for _, n := range []int{0, 1, 3, 8} {
+			t.Run(fmt.Sprintf("%s/%d", name, n), func(t *testing.T) {
+				s, err := NewScheduler(name)
+				if err != nil {
+					t.Fatal(err)
+				}
+				blocks := fakeBlocks(n)
+				checkPicks(t, workers, blocks, s.Select(workers, blocks))
+			})
+		}
+	}
+}
+
+func TestSchedulersNoReadyWorkers(t *testing.T) {
+	workers := fakeWorkers("w1", "w2")
+	for i := range workers {
+		workers[i].Ready = false
+	}
+	for _, name := range []string{"round-robin", "least-loaded", "latency-aware", "consistent-hash"} {
+		s, _ := NewScheduler(name)
+		if picks := s.Select(workers, fakeBlocks(2)); !reflect.DeepEqual(picks, []int{-1, -1}) {
+			t.Errorf("%s: picks = %v, want [-1 -1]", name, picks)
+		}
+	}
+}
+
+func TestNewSchedulerUnknown(t *testing.T) {
+	if _, err := NewScheduler("fifo"); err == nil {
+		t.Fatal("se esperaba error para un scheduler desconocido")
+	}
+}
+
+func TestRoundRobinContinuesRotation(t *testing.T) {
+	rr := &RoundRobin{}
+	workers := fakeWorkers("a", "b", "c")
+
+	steps := []struct {
+		blocks int
+		want   []string
+	}{
+		{2, []string{"a", "b"}},
+		{2, []string{"c", "a"}},
+		{1, []string{"b"}},
+		{3, []string{"c", "a", "b"}},
+		{4, []string{"c", "a", "b", ""}}, // más bloques que workers: el último queda en cola
+	}
+	for i, step := range steps {
+		got := pickedIDs(workers, rr.Select(workers, fakeBlocks(step.blocks)))
+		if !reflect.DeepEqual(got, step.want) {
+			t.Fatalf("llamada %d: got %v, want %v", i, got, step.want)
+		}
+	}
+
+	// si el siguiente worker no está disponible se salta, sin perder la posición
+	workers[0].Ready = false // a
+	if got := pickedIDs(workers, rr.Select(workers, fakeBlocks(1))); !reflect.DeepEqual(got, []string{"c"}) {
+		t.Fatalf("con a ocupado: got %v, want [c]", got)
+	}
+	workers[0].Ready = true
+	if got := pickedIDs(workers, rr.Select(workers, fakeBlocks(1))); !reflect.DeepEqual(got, []string{"a"}) {
+		t.Fatalf("tras c: got %v, want [a]", got)
+	}
+}
+
+func TestRoundRobinWorkerLeaves(t *testing.T) {
+	rr := &RoundRobin{}
+	workers := fakeWorkers("a", "b", "c")
+	rr.Select(workers, fakeBlocks(2)) // último: b
+
+	// b se desconecta: la rotación sigue por el primer ID mayor
+	workers = fakeWorkers("a", "c")
+	if got := pickedIDs(workers, rr.Select(workers, fakeBlocks(2))); !reflect.DeepEqual(got, []string{"c", "a"}) {
+		t.Fatalf("got %v, want [c a]", got)
+	}
+}
+
+func TestLeastLoadedOrdering(t *testing.T) {
+	workers := []WorkerInfo{
+		{ID: "a", Ready: true, Concurrency: 4, Inflight: 2}, // 0.5
+		{ID: "b", Ready: true, Concurrency: 1, Inflight: 1}, // 1
+		{ID: "c", Ready: true, Concurrency: 8, Inflight: 0}, // 0
+		{ID: "d", Ready: true, Concurrency: 0, Inflight: 0}, // 0, concurrencia 0 cuenta como 1
+		{ID: "e", Ready: true, Concurrency: 2, Inflight: 1}, // 0.5
+	}
+	tests := []struct {
+		blocks int
+		want   []string
+	}{
+		{1, []string{"c"}},
+		{2, []string{"c", "d"}},
+		{4, []string{"c", "d", "a", "e"}}, // empates en orden de ID
+		{6, []string{"c", "d", "a", "e", "b", ""}},
+	}
+	for _, tt := range tests {
+		got := pickedIDs(workers, LeastLoaded{}.Select(workers, fakeBlocks(tt.blocks)))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%d bloques: got %v, want %v", tt.blocks, got, tt.want)
+		}
+	}
+}
+
+func TestLatencyAwarePairsWorkWithSpeed(t *testing.T) {
+	workers := []WorkerInfo{
+		{ID: "a", Ready: true, Throughput: 1, Latency: 80 * time.Millisecond},
+		{ID: "b", Ready: true, Throughput: 4, Latency: 20 * time.Millisecond},
+		{ID: "c", Ready: true},                   // sin historial: promedio de a, b y d = 2.5
+		{ID: "d", Ready: false, Throughput: 2.5}, // ocupado, pero cuenta para el promedio
+	}
+	blocks := []BlockInfo{
+		{ShardID: 0, Work: 10},
+		{ShardID: 1, Work: 500},
+		{ShardID: 2, Work: 50},
+	}
+	got := pickedIDs(workers, LatencyAware{}.Select(workers, blocks))
+	want := []string{"a", "b", "c"} // 500→b (4), 50→c (2.5), 10→a (1)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	// con más bloques que workers quedan en cola los de menos trabajo
+	blocks = append(blocks, BlockInfo{ShardID: 3, Work: 1000})
+	got = pickedIDs(workers, LatencyAware{}.Select(workers, blocks))
+	want = []string{"", "c", "a", "b"} // 1000→b, 500→c, 50→a, 10 en cola
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("con 4 bloques: got %v, want %v", got, want)
+	}
+}
+
+func TestLatencyAwareWithoutHistory(t *testing.T) {
+	workers := fakeWorkers("a", "b")
+	blocks := []BlockInfo{{ShardID: 0, Work: 1}, {ShardID: 1, Work: 9}}
+	// sin historial todos valen lo mismo: el bloque más pesado va al primero
+	got := pickedIDs(workers, LatencyAware{}.Select(workers, blocks))
+	if !reflect.DeepEqual(got, []string{"b", "a"}) {
+		t.Fatalf("got %v, want [b a]", got)
+	}
+}
+
+// owners devuelve el worker dueño de cada shard consultando el anillo con un
+// bloque por vez, así ningún shard se desvía por otro de la misma llamada.
+func owners(c ConsistentHash, workers []WorkerInfo, shards int) []string {
+	ids := make([]string, shards)
+	for s := 0; s < shards; s++ {
+		picks := c.Select(workers, []BlockInfo{{ShardID: s, Work: 1}})
+		ids[s] = workers[picks[0]].ID
+	}
+	return ids
+}
+
+func TestConsistentHashStable(t *testing.T) {
+	const shards = 200
+	c := ConsistentHash{Replicas: defaultHashReplicas}
+	workers := fakeWorkers("w1", "w2", "w3", "w4")
+
+	before := owners(c, workers, shards)
+	if again := owners(c, workers, shards); !reflect.DeepEqual(before, again) {
+		t.Fatal("el mismo anillo asignó distinto dueño a un shard")
+	}
+	share := make(map[string]int)
+	for _, id := range before {
+		share[id]++
+	}
+	for _, w := range workers {
+		if share[w.ID] < shards/(4*len(workers)) {
+			t.Errorf("reparto desparejo del anillo: %v", share)
+			break
+		}
+	}
+
+	// entra w5: solo se mueven shards hacia w5
+	joined := owners(c, fakeWorkers("w1", "w2", "w3", "w4", "w5"), shards)
+	moved := 0
+	for s := range before {
+		if joined[s] != before[s] {
+			moved++
+			if joined[s] != "w5" {
+				t.Errorf("shard %d pasó de %s a %s al entrar w5", s, before[s], joined[s])
+			}
+		}
+	}
+	if moved == 0 || moved > shards/2 {
+		t.Errorf("al entrar w5 se movieron %d de %d shards", moved, shards)
+	}
+
+	// sale w2: solo se mueven los shards que eran de w2
+	left := owners(c, fakeWorkers("w1", "w3", "w4"), shards)
+	for s := range before {
+		if before[s] != "w2" && left[s] != before[s] {
+			t.Errorf("shard %d pasó de %s a %s al salir w2", s, before[s], left[s])
+		}
+		if left[s] == "w2" {
+			t.Errorf("shard %d sigue asignado a w2", s)
+		}
+	}
+}
+
+func TestConsistentHashBusyOwner(t *testing.T) {
+	c := ConsistentHash{Replicas: defaultHashReplicas}
+	workers := fakeWorkers("w1", "w2", "w3")
+	block := []BlockInfo{{ShardID: 7, Work: 1}}
+	owner := c.Select(workers, block)[0]
+
+	// dueño ocupado: el shard va a otro worker disponible del anillo
+	workers[owner].Ready = false
+	next := c.Select(workers, block)[0]
+	if next == owner || next < 0 {
+		t.Fatalf("con el dueño ocupado se eligió %d", next)
+	}
+	// el dueño sigue en el anillo: al liberarse recupera el shard
+	workers[owner].Ready = true
+	if got := c.Select(workers, block)[0]; got != owner {
+		t.Fatalf("al liberarse el dueño se eligió %s, want %s", workers[got].ID, workers[owner].ID)
+	}
+}
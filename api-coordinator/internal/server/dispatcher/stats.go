@@ -0,0 +1,38 @@
+package dispatcher
+
+import "time"
+
+// throughputAlpha es el peso de la última medición en el promedio móvil.
+const throughputAlpha = 0.3
+
+// workerStats guarda el rendimiento observado de un worker en tareas pasadas.
+type workerStats struct {
+	Throughput float64       // unidades de trabajo por milisegundo (EWMA)
+	Latency    time.Duration // duración de sus chunks (EWMA)
+	Tasks      int
+}
+
+// recordThroughput actualiza el rendimiento del worker con una tarea terminada.
+func (d *Dispatcher) recordThroughput(workerID string, work int, elapsed time.Duration) {
+	ms := float64(elapsed) / float64(time.Millisecond)
+	if ms <= 0 || work <= 0 {
+		return
+	}
+	sample := float64(work) / ms
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	st, ok := d.stats[workerID]
+	if !ok {
+		st = &workerStats{}
+		d.stats[workerID] = st
+	}
+	if st.Tasks == 0 {
+		st.Throughput = sample
+		st.Latency = elapsed
+	} else {
+		st.Throughput = throughputAlpha*sample + (1-throughputAlpha)*st.Throughput
+		st.Latency = time.Duration(throughputAlpha*float64(elapsed) + (1-throughputAlpha)*float64(st.Latency))
+	}
+	st.Tasks++
+}
@@ -11,23 +11,25 @@ import (
 
 	"goflix/api-coordinator/internal/auth"
 	"goflix/api-coordinator/internal/health"
+	"goflix/api-coordinator/internal/jobs"
 	"goflix/api-coordinator/internal/monitoring"
 	"goflix/api-coordinator/internal/plattform"
 	"goflix/api-coordinator/internal/recommend"
 	tcpserver "goflix/api-coordinator/internal/server/tcp"
 	"goflix/api-coordinator/internal/userstats"
 	"goflix/pkg/styles"
-	"goflix/pkg/types"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
 )
 
 const (
 	defaultMongoRetryInterval = 15 * time.Second
+	defaultMaxAsyncJobs       = 5
 )
 
-func NewRouter(ctx context.Context, dispatchTrigger func(int, int) ([]types.Result, error), server *tcpserver.Server) *gin.Engine {
+func NewRouter(ctx context.Context, dispatchTrigger recommend.DispatchFunc, jobLookup jobs.Lookup, rdb *redis.Client, server *tcpserver.Server) *gin.Engine {
 	r := gin.New()
 
 	r.Use(gin.Logger())
@@ -78,8 +80,17 @@ func NewRouter(ctx context.Context, dispatchTrigger func(int, int) ([]types.Resu
 
 	// Register recommend routes
 	recRepo := recommend.NewMongoRepository(moviesColl, ratingsColl)
-	recSvc := recommend.NewService(dispatchTrigger, recRepo)
+	recSvc := recommend.NewService(dispatchTrigger, recRepo, recommend.AsyncOptions{
+		MaxJobsPerUser:    maxAsyncJobs(),
+		CallbackAllowlist: callbackAllowlist(),
+		Store:             recommend.NewRedisJobStore(rdb),
+	})
 	recHandler := recommend.NewHandler(recSvc)
+	go func() {
+		if err := recSvc.ResumeJobs(ctx); err != nil {
+			log.Printf("[HTTP] No se pudieron reanudar los pedidos asíncronos: %v", err)
+		}
+	}()
 
 	// User Stats
 	userStatsRepo := userstats.NewMongoRepository(moviesColl, ratingsColl)
@@ -101,6 +112,11 @@ func NewRouter(ctx context.Context, dispatchTrigger func(int, int) ([]types.Resu
 	protectedRoot.Use(auth.AuthMiddleware(tokenManager))
 	recHandler.RegisterRoutes(protectedRoot)
 
+	// Estado persistido de los jobs de fondo (Protected)
+	jobsGroup := api.Group("/jobs")
+	jobsGroup.Use(auth.AuthMiddleware(tokenManager))
+	jobs.NewHandler(jobLookup, jobsAdmins()).RegisterRoutes(jobsGroup)
+
 	// Health Check
 	healthSvc := health.NewService(mongoClient, server)
 	healthHandler := health.NewHandler(healthSvc)
@@ -187,3 +203,39 @@ func mongoMaxRetries() int {
 	}
 	return n
 }
+
+func maxAsyncJobs() int {
+	val := strings.TrimSpace(os.Getenv("RECOMMEND_MAX_ASYNC_JOBS"))
+	if val == "" {
+		return defaultMaxAsyncJobs
+	}
+	n, err := strconv.Atoi(val)
+	if err != nil || n < 0 {
+		log.Printf("[HTTP] Valor inválido para RECOMMEND_MAX_ASYNC_JOBS (%s), usando %d", val, defaultMaxAsyncJobs)
+		return defaultMaxAsyncJobs
+	}
+	return n
+}
+
+// jobsAdmins lee JOBS_ADMIN_USERS, user_id separados por coma que pueden
+// consultar cualquier job de fondo.
+func jobsAdmins() []string {
+	var ids []string
+	for _, id := range strings.Split(os.Getenv("JOBS_ADMIN_USERS"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// callbackAllowlist lee RECOMMEND_CALLBACK_ALLOWLIST, hosts separados por coma.
+func callbackAllowlist() []string {
+	var hosts []string
+	for _, h := range strings.Split(os.Getenv("RECOMMEND_CALLBACK_ALLOWLIST"), ",") {
+		if h = strings.ToLower(strings.TrimSpace(h)); h != "" {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
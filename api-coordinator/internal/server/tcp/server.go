@@ -19,11 +19,15 @@ import (
 )
 
 type Worker struct {
-	ID       string
-	Conn     net.Conn
-	State    types.WorkerState
-	LastSeen time.Time
-	SendCh   chan types.Message
+	ID           string
+	Conn         net.Conn
+	State        types.WorkerState
+	Concurrency  int              // goroutines anunciadas en el HELLO
+	Algorithms   []string         // tipos de TASK anunciados en el HELLO
+	MemoryBudget int64            // bytes por tarea anunciados en el HELLO (0 = sin límite)
+	Load         types.WorkerLoad // uso de recursos del último heartbeat
+	LastSeen     time.Time
+	SendCh       chan types.Message
 }
 
 // Server mantiene las conexiones activas y el canal central de entrada.
@@ -164,11 +168,14 @@ func (s *Server) handleConnection(conn net.Conn) {
 			// Registrar el worker
 			s.Mu.Lock()
 			worker := &Worker{
-				ID:       workerID,
-				Conn:     conn,
-				State:    types.WorkerIdle,
-				LastSeen: now,
-				SendCh:   make(chan types.Message, 10),
+				ID:           workerID,
+				Conn:         conn,
+				State:        types.WorkerIdle,
+				Concurrency:  hello.Concurrency,
+				Algorithms:   hello.Algorithms,
+				MemoryBudget: hello.MemoryBudget,
+				LastSeen:     now,
+				SendCh:       make(chan types.Message, 10),
 			}
 			s.Workers[workerID] = worker
 			s.Mu.Unlock()
@@ -222,6 +229,7 @@ func (s *Server) handleHeartbeat(workerID string, data json.RawMessage) {
 	s.Mu.Lock()
 	if worker, ok := s.Workers[workerID]; ok {
 		worker.LastSeen = time.Now()
+		worker.Load = hb.WorkerLoad
 		if hb.Busy {
 			worker.State = types.WorkerBusy
 		} else {
@@ -230,7 +238,8 @@ func (s *Server) handleHeartbeat(workerID string, data json.RawMessage) {
 	}
 	s.Mu.Unlock()
 
-	msg := fmt.Sprintf("[SERVER] Heartbeat recibido de %s (busy=%v)", workerID, hb.Busy)
+	msg := fmt.Sprintf("[SERVER] Heartbeat recibido de %s (busy=%v, cpu=%.1f%%, rss=%dMB, cola=%d)",
+		workerID, hb.Busy, hb.CPU, hb.RSS>>20, hb.QueueLen)
 	styles.PrintFS("info", msg)
 }
 
@@ -0,0 +1,116 @@
+// Package record define el formato en disco de las grabaciones TASK/RESULT
+// que hace el coordinador y que el worker reproduce con "worker replay".
+//
+// Un directorio de grabación tiene dos subdirectorios:
+//
+//	shards/<hash>.json.gz  types.ShardData, uno por contenido de shard
+//	tasks/<nombre>.json.gz Task, un par TASK/RESULT por archivo
+//
+// Todos los archivos son JSON comprimido con gzip.
+package record
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"goflix/pkg/types"
+)
+
+const (
+	ShardsDir = "shards"
+	TasksDir  = "tasks"
+	Ext       = ".json.gz"
+)
+
+// Task es un TASK tal como se envió, junto con el RESULT que devolvió el worker.
+type Task struct {
+	WorkerID   string       `json:"worker_id"`
+	SentAt     time.Time    `json:"sent_at"`
+	DurationMs int64        `json:"duration_ms"`
+	Task       types.Task   `json:"task"`
+	Result     types.Result `json:"result"`
+}
+
+// ShardPath es el archivo del shard con ese hash dentro de dir.
+func ShardPath(dir, hash string) string {
+	return filepath.Join(dir, ShardsDir, hash+Ext)
+}
+
+// TaskPath es el archivo de la grabación de un TASK dentro de dir. El prefijo
+// con la hora ordena los archivos cronológicamente.
+func TaskPath(dir string, rec *Task) string {
+	name := fmt.Sprintf("%s-%s-%d-%d",
+		rec.SentAt.UTC().Format("20060102T150405.000000000"),
+		sanitize(rec.Task.JobID), rec.Task.BlockID.StartID, rec.Task.BlockID.EndID)
+	return filepath.Join(dir, TasksDir, name+Ext)
+}
+
+// sanitize deja el ID utilizable como parte de un nombre de archivo.
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' || r == ' ' {
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// Write guarda v en path como JSON con gzip. Escribe a un temporal y lo
+// renombra, así un lector nunca ve un archivo a medias.
+func Write(path string, v interface{}) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no hace nada tras el rename
+
+	zw := gzip.NewWriter(tmp)
+	if err := json.NewEncoder(zw).Encode(v); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Read carga en v el JSON con gzip de path.
+func Read(path string, v interface{}) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	defer zr.Close()
+	if err := json.NewDecoder(zr).Decode(v); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// ListTasks devuelve los archivos de grabación de dir en orden cronológico.
+func ListTasks(dir string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, TasksDir, "*"+Ext))
+	if err != nil {
+		return nil, err
+	}
+	return paths, nil // Glob ya los devuelve ordenados por nombre
+}
@@ -1,6 +1,9 @@
 package types
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"fmt"
+)
 
 // WorkerState representa el estado actual de un worker.
 type WorkerState int
@@ -14,7 +17,7 @@ const (
 // Message es el contenedor genérico que se envía por TCP.
 // El campo Type indica el tipo de mensaje y Data contiene el payload serializado.
 type Message struct {
-	Type string          `json:"type"` // "HELLO","TASK","RESULT","HEARTBEAT","ERROR","ACK"
+	Type string          `json:"type"` // "HELLO","TASK","LOAD_SHARD","RESULT","PULL","HEARTBEAT","ERROR","ACK"
 	Data json.RawMessage `json:"data"`
 }
 
@@ -22,8 +25,37 @@ type Message struct {
 
 // Hello se envía cuando un worker se conecta al coordinador.
 type Hello struct {
-	WorkerID    string `json:"worker_id"`
-	Concurrency int    `json:"concurrency"` // goroutines disponibles
+	WorkerID    string   `json:"worker_id"`
+	Concurrency int      `json:"concurrency"`          // goroutines disponibles
+	Algorithms  []string `json:"algorithms,omitempty"` // tipos de TASK que sabe procesar
+	// MemoryBudget es la memoria en bytes que el worker dedica a una tarea
+	// (candidatos y objetivos decodificados); 0 = sin límite
+	MemoryBudget int64 `json:"memory_budget,omitempty"`
+}
+
+// Tipos de TASK. Un worker anuncia en el HELLO los que tiene registrados; un
+// HELLO sin algoritmos corresponde a un worker que solo sabe AlgoUserNeighbors.
+// Cada tipo nuevo necesita, además del handler en el worker, un job en el
+// dispatcher que lo envíe y combine sus RESULT.
+const (
+	AlgoUserNeighbors = "user-neighbors" // top-K vecinos de uno o varios usuarios objetivo
+)
+
+// SupportsAlgorithm indica si un worker que anunció advertised sabe procesar
+// TASKs de algorithm.
+func SupportsAlgorithm(advertised []string, algorithm string) bool {
+	if algorithm == "" {
+		algorithm = AlgoUserNeighbors
+	}
+	if len(advertised) == 0 {
+		return algorithm == AlgoUserNeighbors
+	}
+	for _, a := range advertised {
+		if a == algorithm {
+			return true
+		}
+	}
+	return false
 }
 
 // Block representa el rango (o partición) que debe procesar el worker.
@@ -33,14 +65,110 @@ type Block struct {
 }
 
 // Task define una tarea enviada por el coordinador al worker.
+// Si CandidateRatings viene vacío, los candidatos son los del shard ShardID
+// de la época Epoch, enviado antes con LOAD_SHARD.
 type Task struct {
-	JobID   string `json:"job_id"`
-	BlockID Block  `json:"block_id"`
-	// Algo             string                  `json:"algo"` // "user-based" | "item-based"
-	// Sim              string                  `json:"sim"`  // "cosine" | "pearson" | "jaccard"
+	JobID            string                  `json:"job_id"`
+	BlockID          Block                   `json:"block_id"`
 	K                int                     `json:"k"`
+	TargetID         int                     `json:"target_id"`
 	TargetRatings    map[int]float64         `json:"target_ratings"`
-	CandidateRatings map[int]map[int]float64 `json:"candidate_ratings"`
+	Targets          []Target                `json:"targets,omitempty"` // lote de objetivos (reemplaza TargetID/TargetRatings)
+	Epoch            string                  `json:"epoch,omitempty"`
+	ShardID          int                     `json:"shard_id"`
+	ShardHash        string                  `json:"shard_hash,omitempty"` // clave de los candidatos en la caché del worker
+	CandidateRatings map[int]map[int]float64 `json:"candidate_ratings,omitempty"`
+	Deadline         int64                   `json:"deadline,omitempty"`  // unix ms; al vencer el worker responde con lo calculado
+	Algorithm        string                  `json:"algorithm,omitempty"` // tipo de TASK ("" = AlgoUserNeighbors)
+	Scoring
+}
+
+// Métricas de similitud entre usuarios que entiende el worker.
+const (
+	SimCosine         = "cosine"          // coseno sobre los vectores completos
+	SimPearson        = "pearson"         // correlación sobre las películas en común
+	SimAdjustedCosine = "adjusted-cosine" // coseno de los vectores centrados en la media de cada usuario
+	SimJaccard        = "jaccard"         // películas en común / películas calificadas por alguno
+	SimEuclidean      = "euclidean"       // 1 / (1 + distancia euclídea sobre las películas en común)
+)
+
+// Scoring son los parámetros con que el worker compara al usuario objetivo
+// con cada candidato. Viajan en cada TASK.
+type Scoring struct {
+	Sim       string  `json:"sim,omitempty"`       // métrica de similitud ("" = cosine)
+	Shrinkage float64 `json:"shrinkage,omitempty"` // λ de sim·n/(n+λ), con n películas en común; 0 = sin shrinkage
+	// MinOverlap descarta a los candidatos con menos películas en común que
+	// el objetivo; evita similitudes de 1.0 por una sola película compartida.
+	// 0 = sin mínimo.
+	MinOverlap int `json:"min_overlap,omitempty"`
+	// Significance N amortigua la similitud con min(n, N)/N (significance
+	// weighting); 0 = sin amortiguar.
+	Significance int `json:"significance,omitempty"`
+}
+
+// String identifica la configuración, por ejemplo "pearson" o
+// "pearson/min=3/sig=50".
+func (s Scoring) String() string {
+	name := s.Sim
+	if name == "" {
+		name = SimCosine
+	}
+	if s.Shrinkage > 0 {
+		name += fmt.Sprintf("/shrink=%g", s.Shrinkage)
+	}
+	if s.MinOverlap > 0 {
+		name += fmt.Sprintf("/min=%d", s.MinOverlap)
+	}
+	if s.Significance > 0 {
+		name += fmt.Sprintf("/sig=%d", s.Significance)
+	}
+	return name
+}
+
+// Target es un usuario objetivo dentro de una tarea por lotes.
+type Target struct {
+	UserID  int             `json:"user_id"`
+	Ratings map[int]float64 `json:"ratings"`
+}
+
+// TargetNeighbors son los vecinos encontrados para un objetivo de un lote.
+type TargetNeighbors struct {
+	UserID    int        `json:"user_id"`
+	Neighbors []Neighbor `json:"neighbors"`
+}
+
+// ShardData es el payload de LOAD_SHARD: una porción estable de la matriz
+// usuario-película que el worker guarda para tareas siguientes.
+type ShardData struct {
+	Epoch   string                  `json:"epoch"`
+	ShardID int                     `json:"shard_id"`
+	Hash    string                  `json:"hash"` // hash del contenido, clave en la caché del worker
+	Ratings map[int]map[int]float64 `json:"ratings"`
+}
+
+// ErrShardMissing es el Result.Error que devuelve un worker cuando recibe una
+// tarea de un shard que no tiene en caché (nunca lo recibió o lo descartó).
+const ErrShardMissing = "shard_missing"
+
+// ErrTaskTooLarge es el Result.Error que devuelve un worker cuando un TASK o
+// el shard que referencia no entra en su presupuesto de memoria. Es
+// reintentable: el coordinador asigna el chunk a otro worker.
+const ErrTaskTooLarge = "task_too_large"
+
+// ErrTaskInvalid es el Result.Error que devuelve un worker cuando no puede
+// decodificar un TASK. El coordinador reencola el chunk sin esperar al timeout.
+const ErrTaskInvalid = "task_invalid"
+
+// Estimaciones de tamaño que comparten coordinador y workers para respetar
+// Hello.MemoryBudget.
+const (
+	RatingMemoryBytes = 64 // memoria de un rating decodificado en mapas anidados
+	RatingJSONBytes   = 10 // bytes aproximados de un rating en JSON ("123":4.5,)
+)
+
+// RatingsMemory estima la memoria de n ratings decodificados.
+func RatingsMemory(n int) int64 {
+	return int64(n) * RatingMemoryBytes
 }
 
 // Neighbor representa una relación de similitud parcial (resultado intermedio).
@@ -51,16 +179,43 @@ type Neighbor struct {
 
 // Result es la respuesta que el worker envía al coordinador tras procesar un bloque.
 type Result struct {
-	JobID     string     `json:"job_id"`
-	BlockID   Block      `json:"block_id"`
-	Neighbors []Neighbor `json:"neighbors"`
+	JobID     string            `json:"job_id"`
+	BlockID   Block             `json:"block_id"`
+	Neighbors []Neighbor        `json:"neighbors"`
+	Batch     []TargetNeighbors `json:"batch,omitempty"`   // vecinos por objetivo en tareas por lotes
+	Error     string            `json:"error,omitempty"`   // bloque perdido tras agotar reintentos
+	Partial   bool              `json:"partial,omitempty"` // el deadline venció antes de recorrer todos los candidatos
+}
+
+// Prediction es el rating estimado de una película para el usuario objetivo,
+// calculado a partir de sus vecinos más cercanos.
+type Prediction struct {
+	MovieID   int      `json:"movie_id"`
+	Score     float64  `json:"score"`
+	Neighbors []string `json:"neighbors"` // vecinos que calificaron la película
+}
+
+// Pull lo envía un worker cuando queda libre y quiere el siguiente bloque.
+type Pull struct {
+	WorkerID string `json:"worker_id"`
 }
 
 // Heartbeat mantiene viva la conexión y reporta estado del worker.
 type Heartbeat struct {
-	WorkerID string  `json:"worker_id"`
-	Busy     bool    `json:"busy"`
-	CPU      float64 `json:"cpu"` // opcional, uso de CPU o carga
+	WorkerID string `json:"worker_id"`
+	Busy     bool   `json:"busy"`
+	WorkerLoad
+}
+
+// WorkerLoad es el uso de recursos que el worker reporta en cada heartbeat.
+type WorkerLoad struct {
+	CPU         float64 `json:"cpu"`                    // % de CPU del proceso (100 = un core)
+	HostCPU     float64 `json:"host_cpu"`               // % de CPU del host
+	RSS         uint64  `json:"rss"`                    // memoria residente del proceso, en bytes
+	HostMem     float64 `json:"host_mem"`               // % de memoria usada en el host
+	Goroutines  int     `json:"goroutines"`             // goroutines del proceso
+	QueueLen    int     `json:"queue_len"`              // TASK recibidos que todavía no empezaron
+	CurrentTask string  `json:"current_task,omitempty"` // TASK en proceso, "" si está libre
 }
 
 // Envelope asocia un mensaje recibido con el ID del worker que lo envió.
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"goflix/pkg/types"
+	"goflix/worker-node/internal/benchmark"
+	"goflix/worker-node/internal/client"
+	"goflix/worker-node/internal/engine"
+	wtypes "goflix/worker-node/internal/types"
+)
+
+// bench mide el speedup según la cantidad de goroutines y escribe la tabla en
+// el formato pedido. El progreso va a stderr para no mezclarse con la salida.
+func bench(args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	dataDir := dataDirFlag(fs)
+	kind := fs.String("kind", "similarity", "qué medir: similarity (matriz ítem-ítem) o scoring (top-K vecinos de un usuario)")
+	format := fs.String("format", "json", "formato de salida: json, csv o table")
+	out := fs.String("out", "", "archivo de salida (vacío = stdout)")
+	user := fs.Int("user", 0, "usuario objetivo para -kind scoring (0 = el primero del dataset)")
+	k := fs.Int("k", 20, "vecinos para -kind scoring")
+	sim := fs.String("sim", types.SimCosine, "métrica para -kind scoring")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *k <= 0 {
+		return fmt.Errorf("-k debe ser mayor que 0: %d", *k)
+	}
+	scoring := types.Scoring{Sim: *sim}
+	if err := client.ValidateScoring(scoring); err != nil {
+		return fmt.Errorf("-sim: %w", err)
+	}
+
+	var write func(io.Writer, []wtypes.BenchRow) error
+	switch *format {
+	case "json":
+		write = benchmark.WriteJSON
+	case "csv":
+		write = benchmark.WriteCSV
+	case "table":
+		write = benchmark.PrintBench
+	default:
+		return fmt.Errorf("formato desconocido: %q", *format)
+	}
+
+	fmt.Fprintln(os.Stderr, "Cargando dataset desde:", *dataDir)
+	ds, err := engine.LoadDataset(*dataDir)
+	if err != nil {
+		return err
+	}
+	if len(ds.Users) == 0 {
+		return fmt.Errorf("el dataset de %s no tiene usuarios", *dataDir)
+	}
+
+	var rows []wtypes.BenchRow
+	var best int
+	switch *kind {
+	case "similarity":
+		rows, best = benchmark.BenchmarkWorkers(ds.UserRatings, engine.BuildSimilaritiesConcurrent)
+	case "scoring":
+		target := *user
+		if target == 0 {
+			target = ds.Users[0]
+		}
+		if _, ok := ds.UserRatings[target]; !ok {
+			return fmt.Errorf("el usuario %d no tiene ratings", target)
+		}
+		if rows, best, err = benchmark.BenchmarkScoring(ds.UserRatings, target, *k, scoring); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("benchmark desconocido: %q", *kind)
+	}
+	fmt.Fprintf(os.Stderr, "Mejor configuración observada: %d goroutines\n", best)
+
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	return write(w, rows)
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"flag"
+	"runtime"
+
+	"goflix/worker-node/internal/engine"
+)
+
+// local corre el motor item-based de un solo nodo, sin coordinador.
+func local(args []string) error {
+	fs := flag.NewFlagSet("local", flag.ExitOnError)
+	dataDir := dataDirFlag(fs)
+	user := fs.Int("user", 0, "usuario a recomendar (0 = el primero del dataset)")
+	topN := fs.Int("n", 10, "cantidad de recomendaciones")
+	workers := fs.Int("workers", runtime.NumCPU(), "goroutines para las similitudes (0 = elegir con benchmark)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	return engine.Run(engine.Options{
+		DataDir: *dataDir,
+		UserID:  *user,
+		TopN:    *topN,
+		Workers: *workers,
+	})
+}
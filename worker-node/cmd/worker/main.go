@@ -3,85 +3,132 @@ package main
 import (
 	"context"
 	"errors"
+	"flag"
 	"fmt"
 	"goflix/pkg/styles"
 	"goflix/worker-node/internal/client"
-	"net"
+	"goflix/worker-node/internal/data"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 )
 
 const heartbeatInterval = 5 * time.Second
 
-func main() {
-	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
-	defer cancel()
+const usage = `uso: worker [comando] [flags]
 
-	worker := client.NewClient()
-	worker.State = client.StConnecting
+comandos:
+  serve   se conecta al coordinador (COORDINATOR_ADDR) y procesa tareas (default)
+  local   corre el motor item-based sobre un CSV y muestra recomendaciones
+  bench   mide el speedup según la cantidad de goroutines y lo emite en JSON o CSV
+  replay  re-ejecuta TASKs grabados por el coordinador y los compara con su RESULT
 
-	coordinatorAddr := os.Getenv("COORDINATOR_ADDR")
-	if coordinatorAddr == "" {
-		styles.PrintFS("error", "[WORKER] Variable COORDINATOR_ADDR no definida")
-		return
+"worker <comando> -h" muestra los flags de cada comando.
+`
+
+func main() {
+	cmd, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
 	}
 
-	conn, err := net.Dial("tcp", coordinatorAddr)
-	if err != nil {
-		worker.State = client.StDisconnected
-		styles.PrintFS("error", fmt.Sprintf("[WORKER] Error de conexión: %v", err))
+	var err error
+	switch cmd {
+	case "serve":
+		err = serve(args)
+	case "local":
+		err = local(args)
+	case "bench":
+		err = bench(args)
+	case "replay":
+		err = replay(args)
+	case "help":
+		fmt.Print(usage)
 		return
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		styles.PrintFS("error", fmt.Sprintf("[WORKER] %v", err))
+		os.Exit(1)
 	}
+}
 
-	styles.PrintFS("success", "[WORKER] Conexión TCP establecida con el coordinador")
+// dataDirFlag registra -data con default WORKER_DATA_DIR o data.DataDir.
+func dataDirFlag(fs *flag.FlagSet) *string {
+	def := os.Getenv("WORKER_DATA_DIR")
+	if def == "" {
+		def = data.DataDir
+	}
+	return fs.String("data", def, "directorio con ratings.csv y movies.csv (env WORKER_DATA_DIR)")
+}
 
-	defer conn.Close()
+// serve es el modo conectado: registra el worker en el coordinador y procesa
+// TASKs hasta recibir una señal.
+func serve(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
 
-	worker.Conn = conn
-	worker.State = client.StHandshaking
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
 
-	workerID, err := worker.HandShake(conn)
-	if err != nil {
-		styles.PrintFS("error", fmt.Sprintf("[WORKER] Error en el handshake: %v", err))
-		return
+	worker := client.NewClient()
+	if v := os.Getenv("WORKER_CONCURRENCY"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			worker.Concurrency = n
+		} else {
+			styles.PrintFS("error", "[WORKER] WORKER_CONCURRENCY inválido: "+v)
+		}
+	}
+	if v := os.Getenv("WORKER_SHARD_CACHE"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			worker.ShardCacheSize = n
+		} else {
+			styles.PrintFS("error", "[WORKER] WORKER_SHARD_CACHE inválido: "+v)
+		}
 	}
 
-	styles.PrintFS("success", fmt.Sprintf("[WORKER] Handshake completado. Worker ID asignado: %s", workerID))
-
-	heartbeatDone := make(chan error, 1)
-	taskDone := make(chan error, 1)
+	if v := os.Getenv("WORKER_MEMORY_MB"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			worker.MemoryBudget = int64(n) << 20
+		} else {
+			styles.PrintFS("error", "[WORKER] WORKER_MEMORY_MB inválido: "+v)
+		}
+	}
 
-	go func() {
-		styles.PrintFS("info", "[WORKER] Heartbeat iniciado")
-		heartbeatDone <- worker.StartHeartbeat(ctx, heartbeatInterval)
-	}()
+	coordinatorAddr := os.Getenv("COORDINATOR_ADDR")
+	if coordinatorAddr == "" {
+		return errors.New("variable COORDINATOR_ADDR no definida")
+	}
 
-	go func() {
-		styles.PrintFS("info", "[WORKER] Esperando tareas")
-		taskDone <- worker.Process(ctx)
-	}()
+	// endpoint HTTP opcional con /healthz, /readyz y /metrics
+	if addr := os.Getenv("WORKER_HTTP_ADDR"); addr != "" {
+		go func() {
+			styles.PrintFS("info", "[WORKER] Estado HTTP en "+addr)
+			if err := worker.ServeStatus(ctx, addr); err != nil {
+				styles.PrintFS("error", fmt.Sprintf("[WORKER] Error en el servidor HTTP: %v", err))
+			}
+		}()
+	}
 
-	select {
-	case err := <-heartbeatDone:
-		if err != nil && !errors.Is(err, context.Canceled) {
-			styles.PrintFS("error", fmt.Sprintf("[WORKER] Heartbeat detenido: %v", err))
-		}
-		cancel()
-	case err := <-taskDone:
-		if err != nil && !errors.Is(err, context.Canceled) {
-			styles.PrintFS("error", fmt.Sprintf("[WORKER] Proceso detenido: %v", err))
-		}
-		cancel()
-	case <-ctx.Done():
-		styles.PrintFS("info", "[WORKER] Señal recibida, cerrando worker")
+	worker.OnStateChange = func(from, to client.ClientState) {
+		styles.PrintFS("log", fmt.Sprintf("[WORKER] Estado: %s -> %s", from, to))
 	}
 
-	if err := <-heartbeatDone; err != nil && !errors.Is(err, context.Canceled) {
-		styles.PrintFS("error", fmt.Sprintf("[WORKER] Heartbeat detenido: %v", err))
+	supervisor := &client.Supervisor{
+		Addr:              coordinatorAddr,
+		Client:            worker,
+		HeartbeatInterval: heartbeatInterval,
 	}
-	if err := <-taskDone; err != nil && !errors.Is(err, context.Canceled) {
-		styles.PrintFS("error", fmt.Sprintf("[WORKER] Proceso detenido: %v", err))
+	if err := supervisor.Run(ctx); err != nil {
+		return fmt.Errorf("supervisor detenido: %w", err)
 	}
+	styles.PrintFS("info", "[WORKER] Señal recibida, cerrando worker")
+	return nil
 }
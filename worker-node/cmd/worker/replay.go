@@ -0,0 +1,163 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"path/filepath"
+	"runtime"
+
+	"goflix/pkg/record"
+	"goflix/pkg/types"
+	"goflix/worker-node/internal/client"
+)
+
+// replay re-ejecuta offline los TASK grabados por el coordinador
+// (DISPATCHER_RECORD_DIR) y compara la salida con el RESULT grabado.
+func replay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	dir := fs.String("dir", "", "directorio de grabación del coordinador (requerido)")
+	pool := fs.Int("pool", runtime.NumCPU(), "goroutines por TASK")
+	tol := fs.Float64("tol", 1e-9, "diferencia máxima aceptada entre similitudes")
+	verbose := fs.Bool("v", false, "mostrar también los TASK que coinciden")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "uso: worker replay -dir DIR [archivo.json.gz ...]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *dir == "" {
+		fs.Usage()
+		return errors.New("falta -dir")
+	}
+
+	paths := fs.Args()
+	if len(paths) == 0 {
+		var err error
+		if paths, err = record.ListTasks(*dir); err != nil {
+			return err
+		}
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("no hay TASKs grabados en %s", *dir)
+	}
+
+	shards := make(map[string]*client.CandidateBlock)
+	mismatches, skipped := 0, 0
+	for _, path := range paths {
+		var rec record.Task
+		if err := record.Read(path, &rec); err != nil {
+			return err
+		}
+		if retryable(rec.Result.Error) {
+			// grabaciones viejas: el coordinador reenvió el chunk y no hay
+			// nada que reproducir
+			skipped++
+			if *verbose {
+				fmt.Printf("SKIP  %s (worker %s): %s\n", filepath.Base(path), rec.WorkerID, rec.Result.Error)
+			}
+			continue
+		}
+		candidates, err := replayCandidates(*dir, &rec.Task, shards)
+		if err != nil {
+			return fmt.Errorf("%s: %w", filepath.Base(path), err)
+		}
+
+		task := rec.Task
+		task.Deadline = 0 // se reproduce el cálculo completo
+		got := client.Execute(&task, candidates, *pool)
+
+		diffs := diffResults(rec.Result, got, *tol)
+		name := filepath.Base(path)
+		switch {
+		case len(diffs) == 0:
+			if *verbose {
+				fmt.Printf("OK    %s (worker %s)\n", name, rec.WorkerID)
+			}
+		default:
+			mismatches++
+			fmt.Printf("DIFF  %s (worker %s)\n", name, rec.WorkerID)
+			if rec.Result.Partial {
+				fmt.Println("      el RESULT grabado era parcial (deadline vencido); se esperan diferencias")
+			}
+			for _, d := range diffs {
+				fmt.Println("      " + d)
+			}
+		}
+	}
+
+	fmt.Printf("%d TASKs reproducidos, %d con diferencias, %d omitidos por error reintentable\n", len(paths)-skipped, mismatches, skipped)
+	if mismatches > 0 {
+		return fmt.Errorf("%d TASKs no coinciden con el RESULT grabado", mismatches)
+	}
+	return nil
+}
+
+// retryable indica si el RESULT grabado es un error que el coordinador
+// reintenta con otro envío (shard faltante, TASK demasiado grande o inválido).
+func retryable(errMsg string) bool {
+	return errMsg == types.ErrShardMissing || errMsg == types.ErrTaskTooLarge || errMsg == types.ErrTaskInvalid
+}
+
+// replayCandidates devuelve los candidatos del TASK: los que trae inline o
+// los del shard grabado con su hash.
+func replayCandidates(dir string, task *types.Task, cache map[string]*client.CandidateBlock) (*client.CandidateBlock, error) {
+	if len(task.CandidateRatings) > 0 || task.ShardHash == "" {
+		return client.NewCandidateBlock(task.CandidateRatings), nil
+	}
+	if block, ok := cache[task.ShardHash]; ok {
+		return block, nil
+	}
+	var shard types.ShardData
+	if err := record.Read(record.ShardPath(dir, task.ShardHash), &shard); err != nil {
+		return nil, fmt.Errorf("shard %s no grabado: %w", task.ShardHash, err)
+	}
+	block := client.NewCandidateBlock(shard.Ratings)
+	cache[task.ShardHash] = block
+	return block, nil
+}
+
+// diffResults describe las diferencias entre el RESULT grabado y el
+// reproducido.
+func diffResults(want, got types.Result, tol float64) []string {
+	var diffs []string
+	if want.Error != got.Error {
+		diffs = append(diffs, fmt.Sprintf("error: grabado %q, reproducido %q", want.Error, got.Error))
+	}
+	diffs = append(diffs, diffNeighbors("neighbors", want.Neighbors, got.Neighbors, tol)...)
+
+	if len(want.Batch) != len(got.Batch) {
+		diffs = append(diffs, fmt.Sprintf("batch: grabados %d objetivos, reproducidos %d", len(want.Batch), len(got.Batch)))
+	} else {
+		for i := range want.Batch {
+			label := fmt.Sprintf("batch[%d] (user %d)", i, want.Batch[i].UserID)
+			if want.Batch[i].UserID != got.Batch[i].UserID {
+				diffs = append(diffs, fmt.Sprintf("%s: reproducido para user %d", label, got.Batch[i].UserID))
+				continue
+			}
+			diffs = append(diffs, diffNeighbors(label, want.Batch[i].Neighbors, got.Batch[i].Neighbors, tol)...)
+		}
+	}
+
+	return diffs
+}
+
+func diffNeighbors(label string, want, got []types.Neighbor, tol float64) []string {
+	var diffs []string
+	if len(want) != len(got) {
+		diffs = append(diffs, fmt.Sprintf("%s: grabados %d vecinos, reproducidos %d", label, len(want), len(got)))
+	}
+	n := len(want)
+	if len(got) < n {
+		n = len(got)
+	}
+	for i := 0; i < n; i++ {
+		w, g := want[i], got[i]
+		if w.ID != g.ID || math.Abs(w.Similarity-g.Similarity) > tol {
+			diffs = append(diffs, fmt.Sprintf("%s[%d]: grabado %s (%.6f), reproducido %s (%.6f)", label, i, w.ID, w.Similarity, g.ID, g.Similarity))
+		}
+	}
+	return diffs
+}
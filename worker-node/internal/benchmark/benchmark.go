@@ -1,15 +1,27 @@
 package benchmark
 
 import (
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"goflix/worker-node/internal/types"
+	"io"
 	"runtime"
+	"strconv"
 	"time"
 )
 
 type SimilarityBuilder func(userRatings map[int]map[int]float64, workerCount int) (types.SimMatrix, map[int]float64)
 
 func BenchmarkWorkers(userRatings map[int]map[int]float64, builder SimilarityBuilder) ([]types.BenchRow, int) {
+	return sweep(func(workers int) {
+		_, _ = builder(userRatings, workers)
+	})
+}
+
+// sweep mide run con 1 a 4*NumCPU goroutines y devuelve los tiempos con su
+// speedup respecto de 1 goroutine, junto con la mejor cantidad observada.
+func sweep(run func(workers int)) ([]types.BenchRow, int) {
 	// GOMAXPROCS = NumCPU
 	// para que > NumCPU workers muestren overhead
 	cpus := runtime.NumCPU()
@@ -17,12 +29,12 @@ func BenchmarkWorkers(userRatings map[int]map[int]float64, builder SimilarityBui
 
 	maxWorkers := 4 * cpus
 
-	// ejecutamos con workers de 1 - 2*CPU
+	// ejecutamos con workers de 1 - 4*CPU
 	results := make([]types.BenchRow, 0, maxWorkers)
 
 	// baseline con 1 worker
 	start := time.Now()
-	_, _ = builder(userRatings, 1)
+	run(1)
 	baseMs := time.Since(start).Milliseconds()
 	if baseMs == 0 {
 		baseMs = 1
@@ -34,8 +46,11 @@ func BenchmarkWorkers(userRatings map[int]map[int]float64, builder SimilarityBui
 	bestMs := baseMs
 	for w := 2; w <= maxWorkers; w++ {
 		t0 := time.Now()
-		_, _ = builder(userRatings, w)
+		run(w)
 		ms := time.Since(t0).Milliseconds()
+		if ms == 0 {
+			ms = 1
+		}
 		sp := float64(baseMs) / float64(ms)
 		results = append(results, types.BenchRow{Workers: w, Millis: ms, Speedup: sp})
 		if ms < bestMs {
@@ -46,11 +61,42 @@ func BenchmarkWorkers(userRatings map[int]map[int]float64, builder SimilarityBui
 	return results, results[bestIdx].Workers
 }
 
-func PrintBench(results []types.BenchRow) {
-	fmt.Println("=== Benchmark de goroutines para cálculo de similitudes ===")
-	fmt.Printf("GOMAXPROCS = %d (NumCPU)", runtime.NumCPU())
-	fmt.Printf("%8s  %12s  %8s", "workers", "ms", "speedup")
+// PrintBench escribe los resultados como una tabla legible.
+func PrintBench(w io.Writer, results []types.BenchRow) error {
+	fmt.Fprintln(w, "=== Benchmark de goroutines para cálculo de similitudes ===")
+	fmt.Fprintf(w, "GOMAXPROCS = %d (NumCPU)\n", runtime.NumCPU())
+	fmt.Fprintf(w, "%8s  %12s  %8s\n", "workers", "ms", "speedup")
 	for _, r := range results {
-		fmt.Printf("%8d  %12d  %8.2f", r.Workers, r.Millis, r.Speedup)
+		if _, err := fmt.Fprintf(w, "%8d  %12d  %8.2f\n", r.Workers, r.Millis, r.Speedup); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteJSON escribe los resultados como un arreglo JSON.
+func WriteJSON(w io.Writer, results []types.BenchRow) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(results)
+}
+
+// WriteCSV escribe los resultados como CSV con encabezado.
+func WriteCSV(w io.Writer, results []types.BenchRow) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"workers", "ms", "speedup"}); err != nil {
+		return err
+	}
+	for _, r := range results {
+		row := []string{
+			strconv.Itoa(r.Workers),
+			strconv.FormatInt(r.Millis, 10),
+			strconv.FormatFloat(r.Speedup, 'f', 3, 64),
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
 	}
+	cw.Flush()
+	return cw.Error()
 }
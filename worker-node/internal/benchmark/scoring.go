@@ -0,0 +1,34 @@
+package benchmark
+
+import (
+	"goflix/pkg/types"
+	"goflix/worker-node/internal/client"
+	wtypes "goflix/worker-node/internal/types"
+)
+
+// scoringRounds repite el scoring en cada medición para que los tiempos de
+// un dataset chico no queden en 0-1 ms.
+const scoringRounds = 20
+
+// BenchmarkScoring mide el scoring de un TASK (un objetivo contra todos los
+// candidatos) variando el tamaño del pool de goroutines del worker. Si el
+// scoring falla (por ejemplo, una métrica desconocida) devuelve el error en
+// vez de una tabla de tiempos sin sentido.
+func BenchmarkScoring(userRatings map[int]map[int]float64, targetID, k int, scoring types.Scoring) ([]wtypes.BenchRow, int, error) {
+	// los vectores se arman una sola vez, como hace el worker al recibir un shard
+	block := client.NewCandidateBlock(userRatings)
+	if _, err := client.ScoreUser(scoring, targetID, userRatings[targetID], block, k, 1); err != nil {
+		return nil, 0, err
+	}
+
+	var runErr error
+	rows, best := sweep(func(workers int) {
+		for i := 0; i < scoringRounds && runErr == nil; i++ {
+			_, runErr = client.ScoreUser(scoring, targetID, userRatings[targetID], block, k, workers)
+		}
+	})
+	if runErr != nil {
+		return nil, 0, runErr
+	}
+	return rows, best, nil
+}
@@ -0,0 +1,95 @@
+package client
+
+import (
+	"fmt"
+	"goflix/pkg/types"
+	"goflix/worker-node/internal/sparse"
+	"sort"
+	"sync"
+	"time"
+)
+
+// TaskContext es lo que recibe el handler de un algoritmo: el TASK ya
+// parseado, sus candidatos decodificados y el RESULT a completar.
+type TaskContext struct {
+	Task       *types.Task
+	Candidates *CandidateBlock
+	Deadline   time.Time // cero = sin límite
+	Pool       int       // goroutines disponibles para la tarea
+	Result     *types.Result
+}
+
+// Expired indica si venció el deadline de la tarea.
+func (tc *TaskContext) Expired() bool {
+	return expired(tc.Deadline)
+}
+
+// AlgorithmHandler procesa un tipo de TASK. Un error se devuelve al
+// coordinador en Result.Error.
+type AlgorithmHandler func(tc *TaskContext) error
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]AlgorithmHandler{}
+)
+
+// Register agrega un algoritmo al worker. Debe llamarse antes del HELLO para
+// que el coordinador sepa que puede enviarle ese tipo de TASK. Hoy el
+// coordinador solo despacha types.AlgoUserNeighbors: la predicción de ratings
+// se hace en el coordinador al combinar los vecinos y la similitud ítem-ítem
+// solo se calcula en el modo local (engine); ALS y evaluación no existen.
+func Register(name string, handler AlgorithmHandler) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, dup := registry[name]; dup {
+		panic("algoritmo registrado dos veces: " + name)
+	}
+	registry[name] = handler
+}
+
+// Algorithms devuelve los algoritmos registrados, ordenados por nombre.
+func Algorithms() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func lookupAlgorithm(name string) (AlgorithmHandler, error) {
+	if name == "" {
+		name = types.AlgoUserNeighbors
+	}
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	handler, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("algoritmo no soportado: %q", name)
+	}
+	return handler, nil
+}
+
+func init() {
+	Register(types.AlgoUserNeighbors, userNeighbors)
+}
+
+// userNeighbors busca los top-K vecinos del usuario objetivo, o de cada
+// objetivo en las tareas por lotes, entre los candidatos del shard.
+func userNeighbors(tc *TaskContext) error {
+	task := tc.Task
+	sc, err := newScorer(task.Scoring)
+	if err != nil {
+		return err
+	}
+
+	if len(task.Targets) > 0 {
+		tc.Result.Batch, tc.Result.Partial = scoreBatch(sc, task.Targets, tc.Candidates, task.K, tc.Deadline, tc.Pool)
+		return nil
+	}
+	target := sparse.FromMap(task.TargetRatings)
+	tc.Result.Neighbors, tc.Result.Partial = scoreTarget(sc, task.TargetID, &target, tc.Candidates, task.K, tc.Deadline, tc.Pool)
+	return nil
+}
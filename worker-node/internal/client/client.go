@@ -4,15 +4,14 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"goflix/pkg/styles"
 	"goflix/pkg/tcp"
 	"goflix/pkg/types"
-	"math"
 	"net"
 	"runtime"
-	"sort"
-	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -27,44 +26,81 @@ const (
 	StShuttingDown
 )
 
+func (s ClientState) String() string {
+	switch s {
+	case StDisconnected:
+		return "disconnected"
+	case StConnecting:
+		return "connecting"
+	case StHandshaking:
+		return "handshaking"
+	case StReady:
+		return "ready"
+	case StWorking:
+		return "working"
+	case StShuttingDown:
+		return "shutting-down"
+	}
+	return fmt.Sprintf("ClientState(%d)", int(s))
+}
+
 type WorkerClient struct {
 	ID          string
 	Conn        net.Conn
-	State       ClientState // estado del ciclo de vida del cliente
+	State       ClientState // estado del ciclo de vida del cliente; leer con CurrentState
 	Busy        bool        // ocupación local (equivalente a “idle/busy”)
 	CurrentTask *types.Task // nil si no hay trabajo
 	LastSeen    time.Time   // para métricas/timeouts
-	connMu      sync.Mutex
+	Concurrency int         // goroutines para puntuar los candidatos de cada TASK
+	// ShardCacheSize es cuántos shards decodificados se mantienen en caché
+	ShardCacheSize int
+	// MemoryBudget es la memoria en bytes que puede ocupar una tarea
+	// decodificada; se anuncia en el HELLO y limita también el total de la
+	// caché de shards. 0 = sin límite
+	MemoryBudget int64
+	shards       shardCache   // shards de la matriz de ratings (LOAD_SHARD), por hash
+	queued       atomic.Int32 // TASK leídos del socket que todavía no empezaron
+	metrics      workerMetrics
+	connMu       sync.Mutex
+	stateMu      sync.Mutex // protege State, Busy y CurrentTask
+	// OnStateChange, si no es nil, se llama en cada transición de State
+	OnStateChange func(from, to ClientState)
 }
 
-var payload struct {
-	WorkerID string `json:"worker_id"`
-}
+// ErrConnectionLost indica que se cortó la conexión con el coordinador.
+var ErrConnectionLost = errors.New("conexión con el coordinador perdida")
+
+// taskQueueSize es cuántos mensajes leídos pueden esperar a ser procesados.
+const taskQueueSize = 16
 
 func NewClient() *WorkerClient {
 	return &WorkerClient{
-		ID:          "",
-		Conn:        nil,
-		State:       StDisconnected,
-		Busy:        false,
-		CurrentTask: nil,
-		LastSeen:    time.Now(),
+		ID:             "",
+		Conn:           nil,
+		State:          StDisconnected,
+		Busy:           false,
+		CurrentTask:    nil,
+		LastSeen:       time.Now(),
+		Concurrency:    runtime.NumCPU(),
+		ShardCacheSize: defaultShardCacheSize,
 	}
 }
 
 func (wc *WorkerClient) HandShake(conn net.Conn) (string, error) {
-	wc.State = StHandshaking
+	wc.setState(StHandshaking)
 
 	// Enviar HELLO (ID vacío, el server lo asigna)
 	hello := types.Hello{
-		WorkerID:    "", // el server lo da
-		Concurrency: runtime.NumCPU(),
+		WorkerID:     "", // el server lo da
+		Concurrency:  wc.Concurrency,
+		Algorithms:   Algorithms(),
+		MemoryBudget: wc.MemoryBudget,
 	}
 	data, _ := json.Marshal(hello)
 	msg := types.Message{Type: "HELLO", Data: data}
 
 	if err := tcp.WriteMessage(conn, msg); err != nil {
-		wc.State = StDisconnected
+		wc.setState(StDisconnected)
 		return "", err
 	}
 
@@ -74,28 +110,33 @@ func (wc *WorkerClient) HandShake(conn net.Conn) (string, error) {
 
 	ack, err := tcp.ReadMessage(conn)
 	if err != nil {
-		wc.State = StDisconnected
+		wc.setState(StDisconnected)
 		return "", err
 	}
 	if ack.Type != "ACK" {
-		wc.State = StDisconnected
+		wc.setState(StDisconnected)
 		return "", errors.New("handshake: esperaba ACK del servidor")
 	}
 
 	// Parsear el worker_id devuelto
+	var payload struct {
+		WorkerID string `json:"worker_id"`
+	}
 	if err := json.Unmarshal(ack.Data, &payload); err != nil {
-		wc.State = StDisconnected
+		wc.setState(StDisconnected)
 		return "", err
 	}
 	if payload.WorkerID == "" {
-		wc.State = StDisconnected
+		wc.setState(StDisconnected)
 		return "", errors.New("handshake: ACK sin worker_id")
 	}
 
 	// Actualizar estado del cliente
 	wc.ID = payload.WorkerID
+	wc.connMu.Lock()
 	wc.Conn = conn
-	wc.State = StReady
+	wc.connMu.Unlock()
+	wc.setState(StReady)
 	wc.LastSeen = time.Now()
 	return wc.ID, nil
 }
@@ -111,6 +152,15 @@ func (wc *WorkerClient) sendMessage(msg types.Message) error {
 	return tcp.WriteMessage(wc.Conn, msg)
 }
 
+// pull avisa al coordinador que el worker está libre para el siguiente chunk.
+func (wc *WorkerClient) pull() error {
+	data, err := json.Marshal(types.Pull{WorkerID: wc.ID})
+	if err != nil {
+		return err
+	}
+	return wc.sendMessage(types.Message{Type: "PULL", Data: data})
+}
+
 func (wc *WorkerClient) StartHeartbeat(ctx context.Context, interval time.Duration) error {
 	if wc.ID == "" {
 		return errors.New("heartbeat: worker sin ID asignado")
@@ -127,16 +177,18 @@ func (wc *WorkerClient) StartHeartbeat(ctx context.Context, interval time.Durati
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
+	sampler := newLoadSampler()
 	for {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
 		case <-ticker.C:
 			hb := types.Heartbeat{
-				WorkerID: wc.ID,
-				Busy:     wc.Busy,
-				CPU:      0,
+				WorkerID:   wc.ID,
+				WorkerLoad: sampler.sample(),
 			}
+			hb.QueueLen = int(wc.queued.Load())
+			hb.Busy, hb.CurrentTask = wc.status()
 
 			data, err := json.Marshal(hb)
 			if err != nil {
@@ -146,7 +198,7 @@ func (wc *WorkerClient) StartHeartbeat(ctx context.Context, interval time.Durati
 			msg := types.Message{Type: "HEARTBEAT", Data: data}
 
 			if err := wc.sendMessage(msg); err != nil {
-				wc.State = StDisconnected
+				wc.setState(StDisconnected)
 				return err
 			}
 
@@ -155,6 +207,73 @@ func (wc *WorkerClient) StartHeartbeat(ctx context.Context, interval time.Durati
 	}
 }
 
+// CurrentState devuelve el estado del ciclo de vida del cliente.
+func (wc *WorkerClient) CurrentState() ClientState {
+	wc.stateMu.Lock()
+	defer wc.stateMu.Unlock()
+	return wc.State
+}
+
+func (wc *WorkerClient) setState(st ClientState) {
+	wc.stateMu.Lock()
+	prev := wc.State
+	wc.State = st
+	hook := wc.OnStateChange
+	wc.stateMu.Unlock()
+
+	if hook != nil && prev != st {
+		hook(prev, st)
+	}
+}
+
+// setCurrentTask marca el worker como ocupado con task, o libre si es nil.
+func (wc *WorkerClient) setCurrentTask(task *types.Task) {
+	wc.stateMu.Lock()
+	wc.CurrentTask = task
+	wc.Busy = task != nil
+	wc.stateMu.Unlock()
+
+	if task != nil {
+		wc.setState(StWorking)
+	} else {
+		wc.setState(StReady)
+	}
+}
+
+// status devuelve si el worker está ocupado y qué TASK procesa.
+func (wc *WorkerClient) status() (busy bool, current string) {
+	wc.stateMu.Lock()
+	defer wc.stateMu.Unlock()
+	if wc.CurrentTask != nil {
+		current = taskLabel(wc.CurrentTask)
+	}
+	return wc.Busy, current
+}
+
+// readLoop lee los mensajes del coordinador y los deja en msgs, para que el
+// heartbeat pueda reportar cuántos TASK esperan mientras se procesa otro.
+// Un error de lectura es fatal: el framing se pierde o el socket está muerto,
+// así que se reporta en errc y el supervisor reconecta.
+func (wc *WorkerClient) readLoop(ctx context.Context, conn net.Conn, msgs chan<- types.Message, errc chan<- error) {
+	for {
+		msg, err := tcp.ReadMessage(conn)
+		if err != nil {
+			if ctx.Err() == nil {
+				errc <- fmt.Errorf("%w: %v", ErrConnectionLost, err)
+			}
+			return
+		}
+		if msg.Type == "TASK" {
+			wc.queued.Add(1)
+		}
+		select {
+		case msgs <- msg:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
 func (wc *WorkerClient) Process(ctx context.Context) error {
 	if wc.ID == "" {
 		styles.PrintFS("error", "[WORKER] Worker sin ID asignado")
@@ -166,95 +285,151 @@ func (wc *WorkerClient) Process(ctx context.Context) error {
 		return errors.New("Worker sin conexion")
 	}
 
-	// Calculo de cosine similarity
-	// leer mensajes en un loop y si es un TASK, procesarlo
+	// pedir el primer chunk; luego se pide otro tras cada RESULT
+	if err := wc.pull(); err != nil {
+		styles.PrintFS("error", "[WORKER] Error al enviar PULL")
+		return err
+	}
+
+	msgs := make(chan types.Message, taskQueueSize)
+	readErr := make(chan error, 1)
+	wc.queued.Store(0)
+	go wc.readLoop(ctx, wc.Conn, msgs, readErr)
 
 	for {
+		var msg types.Message
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		default:
-			msg, err := tcp.ReadMessage(wc.Conn)
-			if err != nil {
-				styles.PrintFS("error", "[WORKER] Error leyendo mensaje:")
-				// skip
-				continue
+		case err := <-readErr:
+			styles.PrintFS("error", "[WORKER] "+err.Error())
+			return err
+		case msg = <-msgs:
+		}
+
+		if msg.Type == "LOAD_SHARD" {
+			if err := wc.loadShard(msg.Data); err != nil {
+				wc.metrics.addError(errDecode)
+				styles.PrintFS("error", "[WORKER] Error al parsear LOAD_SHARD")
 			}
+			continue
+		}
 
-			styles.PrintFS("log", "Empezando tarea")
-			if msg.Type == "TASK" {
-				time.Sleep(600 * time.Millisecond)
+		styles.PrintFS("log", "Empezando tarea")
+		if msg.Type == "TASK" {
+			wc.queued.Add(-1)
 
-				// parsear msg como Task
-				var task types.Task
-				if err := json.Unmarshal(msg.Data, &task); err != nil {
-					styles.PrintFS("error", "[WORKER] Error al parsear TASK")
-					continue
+			if result, ok := wc.rejectOversized(msg.Data); ok {
+				styles.PrintFS("error", fmt.Sprintf("[WORKER] TASK de %d bytes supera el presupuesto de memoria, rechazado", len(msg.Data)))
+				wc.metrics.observeTask(0, taskResult(&result))
+				if err := wc.sendResult(result); err != nil {
+					return err
 				}
+				continue
+			}
 
-				styles.PrintFS("info", "[WORKER] Procesando TASK "+task.JobID)
-
-				neighbors := make([]types.Neighbor, 0, len(task.CandidateRatings))
-				for candidateID, candidateRatings := range task.CandidateRatings {
-					sim := cosineSimilarity(task.TargetRatings, candidateRatings)
-					if sim > 0 { // Solo guardamos si hay alguna similitud positiva (opcional)
-						neighbors = append(neighbors, types.Neighbor{
-							ID:         strconv.Itoa(candidateID),
-							Similarity: sim,
-						})
-					}
+			// parsear msg como Task
+			var task types.Task
+			if err := json.Unmarshal(msg.Data, &task); err != nil {
+				wc.metrics.addError(errDecode)
+				styles.PrintFS("error", "[WORKER] Error al parsear TASK")
+				// avisar al coordinador para que reasigne el chunk enseguida
+				result := errorResult(msg.Data, types.ErrTaskInvalid)
+				wc.metrics.observeTask(0, taskResult(&result))
+				if err := wc.sendResult(result); err != nil {
+					return err
 				}
+				continue
+			}
 
-				// Ordenar por similitud descendente
-				sort.Slice(neighbors, func(i, j int) bool {
-					return neighbors[i].Similarity > neighbors[j].Similarity
-				})
+			styles.PrintFS("info", "[WORKER] Procesando TASK "+task.JobID)
 
-				// Mantener solo los top K
-				if len(neighbors) > task.K {
-					neighbors = neighbors[:task.K]
-				}
-
-				// enviar RESULT
-				result := types.Result{
-					JobID:     task.JobID,
-					BlockID:   task.BlockID,
-					Neighbors: neighbors,
-				}
-				data, err := json.Marshal(result)
-				if err != nil {
-					styles.PrintFS("error", "[WORKER] Error al hacer Marshall")
-					return err
-				}
+			wc.setCurrentTask(&task)
+			start := time.Now()
+			result := wc.runTask(&task)
+			wc.metrics.observeTask(time.Since(start), taskResult(&result))
+			wc.setCurrentTask(nil)
+			if result.Partial {
+				styles.PrintFS("info", "[WORKER] Deadline vencido, enviando resultado parcial de "+task.JobID)
+			}
 
-				resultMsg := types.Message{Type: "RESULT", Data: data}
-				if err := wc.sendMessage(resultMsg); err != nil {
-					styles.PrintFS("error", "[WORKER] Error al enviar RESULT")
-					return err
-				}
+			if err := wc.sendResult(result); err != nil {
+				return err
 			}
 		}
 	}
 }
 
-func cosineSimilarity(a, b map[int]float64) float64 {
-	var dotProduct, normA, normB float64
+// sendResult envía el RESULT y pide el siguiente chunk.
+func (wc *WorkerClient) sendResult(result types.Result) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		styles.PrintFS("error", "[WORKER] Error al hacer Marshall")
+		return err
+	}
 
-	// Iterar sobre las claves de 'a' para encontrar coincidencias en 'b'
-	for key, valA := range a {
-		if valB, ok := b[key]; ok {
-			dotProduct += valA * valB
-		}
-		normA += valA * valA
+	resultMsg := types.Message{Type: "RESULT", Data: data}
+	if err := wc.sendMessage(resultMsg); err != nil {
+		wc.metrics.addError(errSend)
+		styles.PrintFS("error", "[WORKER] Error al enviar RESULT")
+		return err
 	}
 
-	for _, valB := range b {
-		normB += valB * valB
+	if err := wc.pull(); err != nil {
+		wc.metrics.addError(errSend)
+		styles.PrintFS("error", "[WORKER] Error al enviar PULL")
+		return err
+	}
+	return nil
+}
+
+// runTask busca el handler del algoritmo del TASK y arma el RESULT. Los
+// errores (shard faltante, algoritmo desconocido) viajan en Result.Error.
+func (wc *WorkerClient) runTask(task *types.Task) types.Result {
+	result := types.Result{
+		JobID:   task.JobID,
+		BlockID: task.BlockID,
 	}
 
-	if normA == 0 || normB == 0 {
-		return 0.0
+	if wc.shards.isRejected(task.ShardHash) {
+		// el shard no entraba en memoria: que el coordinador use otro worker
+		result.Error = types.ErrTaskTooLarge
+		return result
 	}
 
-	return dotProduct / (math.Sqrt(normA) * math.Sqrt(normB))
+	candidates, ok := wc.candidates(task)
+	if !ok {
+		// el coordinador reenvía el shard y vuelve a encolar el chunk
+		styles.PrintFS("error", fmt.Sprintf("[WORKER] Shard %d (hash %s) no está en caché", task.ShardID, task.ShardHash))
+		result.Error = types.ErrShardMissing
+		return result
+	}
+
+	return Execute(task, candidates, wc.Concurrency)
+}
+
+// Execute corre el handler del algoritmo del TASK sobre candidates con pool
+// goroutines, sin conexión con el coordinador. Además de runTask lo usa
+// "worker replay" para reproducir tareas grabadas.
+func Execute(task *types.Task, candidates *CandidateBlock, pool int) types.Result {
+	result := types.Result{
+		JobID:   task.JobID,
+		BlockID: task.BlockID,
+	}
+
+	handler, err := lookupAlgorithm(task.Algorithm)
+	if err == nil {
+		err = handler(&TaskContext{
+			Task:       task,
+			Candidates: candidates,
+			Deadline:   taskDeadline(task),
+			Pool:       pool,
+			Result:     &result,
+		})
+	}
+	if err != nil {
+		styles.PrintFS("error", "[WORKER] "+err.Error())
+		result.Error = err.Error()
+	}
+	return result
 }
@@ -0,0 +1,45 @@
+package client
+
+import (
+	"encoding/json"
+
+	"goflix/pkg/types"
+)
+
+// decodedMemory estima la memoria que ocupan decodificados n bytes de JSON de
+// ratings.
+func decodedMemory(n int) int64 {
+	return int64(n) / types.RatingJSONBytes * types.RatingMemoryBytes
+}
+
+// fitsBudget indica si un mensaje de n bytes se puede decodificar sin superar
+// MemoryBudget.
+func (wc *WorkerClient) fitsBudget(n int) bool {
+	return wc.MemoryBudget <= 0 || decodedMemory(n) <= wc.MemoryBudget
+}
+
+// rejectOversized arma el RESULT de rechazo si el TASK no entra en el
+// presupuesto de memoria. Solo decodifica los campos que identifican el
+// chunk: los mapas de ratings se saltean sin reservarlos.
+func (wc *WorkerClient) rejectOversized(data json.RawMessage) (types.Result, bool) {
+	if wc.fitsBudget(len(data)) {
+		return types.Result{}, false
+	}
+	return errorResult(data, types.ErrTaskTooLarge), true
+}
+
+// errorResult arma un RESULT con errMsg para el TASK data. Solo decodifica
+// los campos que identifican el chunk, así sirve aunque el resto del TASK sea
+// inválido o demasiado grande.
+func errorResult(data json.RawMessage, errMsg string) types.Result {
+	var header struct {
+		JobID   string      `json:"job_id"`
+		BlockID types.Block `json:"block_id"`
+	}
+	_ = json.Unmarshal(data, &header)
+	return types.Result{
+		JobID:   header.JobID,
+		BlockID: header.BlockID,
+		Error:   errMsg,
+	}
+}
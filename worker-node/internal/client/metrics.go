@@ -0,0 +1,166 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"goflix/pkg/types"
+)
+
+// taskDurationBuckets son los límites (en segundos) del histograma de
+// duración de tareas.
+var taskDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// Resultado de una tarea en goflix_worker_tasks_total.
+const (
+	taskOK           = "ok"
+	taskPartial      = "partial"
+	taskShardMissing = "shard_missing"
+	taskTooLarge     = "too_large"
+	taskError        = "error"
+)
+
+// Tipo de error en goflix_worker_errors_total.
+const (
+	errDecode     = "decode"     // TASK o LOAD_SHARD con JSON inválido
+	errConnection = "connection" // conexión perdida o fallida
+	errSend       = "send"       // no se pudo enviar RESULT/PULL
+)
+
+// workerMetrics acumula los contadores que expone /metrics. Se mantienen
+// entre reconexiones.
+type workerMetrics struct {
+	bytesIn  atomic.Uint64
+	bytesOut atomic.Uint64
+
+	mu      sync.Mutex
+	tasks   map[string]uint64 // resultado -> tareas
+	errors  map[string]uint64 // tipo -> errores
+	buckets []uint64          // acumulado por límite de taskDurationBuckets
+	count   uint64
+	sumSecs float64
+}
+
+func (m *workerMetrics) observeTask(d time.Duration, result string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.tasks == nil {
+		m.tasks = make(map[string]uint64)
+		m.buckets = make([]uint64, len(taskDurationBuckets))
+	}
+	m.tasks[result]++
+
+	secs := d.Seconds()
+	for i, le := range taskDurationBuckets {
+		if secs <= le {
+			m.buckets[i]++
+		}
+	}
+	m.count++
+	m.sumSecs += secs
+}
+
+func (m *workerMetrics) addError(kind string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.errors == nil {
+		m.errors = make(map[string]uint64)
+	}
+	m.errors[kind]++
+}
+
+// taskResult clasifica un RESULT para las métricas.
+func taskResult(result *types.Result) string {
+	switch {
+	case result.Error == types.ErrShardMissing:
+		return taskShardMissing
+	case result.Error == types.ErrTaskTooLarge:
+		return taskTooLarge
+	case result.Error != "":
+		return taskError
+	case result.Partial:
+		return taskPartial
+	}
+	return taskOK
+}
+
+// countingConn cuenta los bytes leídos y escritos en la conexión.
+type countingConn struct {
+	net.Conn
+	in, out *atomic.Uint64
+}
+
+func (c countingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.in.Add(uint64(n))
+	return n, err
+}
+
+func (c countingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.out.Add(uint64(n))
+	return n, err
+}
+
+// writeMetrics escribe las métricas del worker en el formato de texto de
+// Prometheus.
+func (wc *WorkerClient) writeMetrics(w io.Writer) {
+	m := &wc.metrics
+	busy, _ := wc.status()
+	state := wc.CurrentState()
+
+	gauge := func(name, help string, v float64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %g\n", name, help, name, name, v)
+	}
+	counter := func(name, help string, v uint64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", name, help, name, name, v)
+	}
+
+	m.mu.Lock()
+	fmt.Fprintf(w, "# HELP goflix_worker_tasks_total Tareas procesadas por resultado.\n# TYPE goflix_worker_tasks_total counter\n")
+	for _, result := range []string{taskOK, taskPartial, taskShardMissing, taskTooLarge, taskError} {
+		fmt.Fprintf(w, "goflix_worker_tasks_total{result=%q} %d\n", result, m.tasks[result])
+	}
+
+	fmt.Fprintf(w, "# HELP goflix_worker_task_duration_seconds Duración de cada tarea.\n# TYPE goflix_worker_task_duration_seconds histogram\n")
+	for i, le := range taskDurationBuckets {
+		var n uint64
+		if m.buckets != nil {
+			n = m.buckets[i]
+		}
+		fmt.Fprintf(w, "goflix_worker_task_duration_seconds_bucket{le=\"%g\"} %d\n", le, n)
+	}
+	fmt.Fprintf(w, "goflix_worker_task_duration_seconds_bucket{le=\"+Inf\"} %d\n", m.count)
+	fmt.Fprintf(w, "goflix_worker_task_duration_seconds_sum %g\n", m.sumSecs)
+	fmt.Fprintf(w, "goflix_worker_task_duration_seconds_count %d\n", m.count)
+
+	fmt.Fprintf(w, "# HELP goflix_worker_errors_total Errores por tipo.\n# TYPE goflix_worker_errors_total counter\n")
+	for _, kind := range []string{errConnection, errDecode, errSend} {
+		fmt.Fprintf(w, "goflix_worker_errors_total{kind=%q} %d\n", kind, m.errors[kind])
+	}
+	m.mu.Unlock()
+
+	counter("goflix_worker_received_bytes_total", "Bytes recibidos del coordinador.", m.bytesIn.Load())
+	counter("goflix_worker_sent_bytes_total", "Bytes enviados al coordinador.", m.bytesOut.Load())
+
+	hits, misses, entries := wc.shards.stats()
+	counter("goflix_worker_shard_cache_hits_total", "TASK cuyos candidatos estaban en caché.", hits)
+	counter("goflix_worker_shard_cache_misses_total", "TASK cuyo shard no estaba en caché.", misses)
+	gauge("goflix_worker_shard_cache_entries", "Shards decodificados en caché.", float64(entries))
+
+	gauge("goflix_worker_state", "Estado del cliente (0=disconnected, 1=connecting, 2=handshaking, 3=ready, 4=working, 5=shutting-down).", float64(state))
+	gauge("goflix_worker_busy", "1 si el worker está procesando un TASK.", boolGauge(busy))
+	gauge("goflix_worker_queue_length", "TASK recibidos que todavía no empezaron.", float64(wc.queued.Load()))
+	gauge("goflix_worker_concurrency", "Goroutines por TASK.", float64(wc.Concurrency))
+}
+
+func boolGauge(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
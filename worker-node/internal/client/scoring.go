@@ -0,0 +1,229 @@
+package client
+
+import (
+	"container/heap"
+	"goflix/pkg/types"
+	"goflix/worker-node/internal/sparse"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// taskDeadline convierte el deadline del TASK (unix ms) a time.Time; el valor
+// cero significa que la tarea no tiene límite.
+func taskDeadline(task *types.Task) time.Time {
+	if task.Deadline == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(task.Deadline)
+}
+
+// expired indica si venció el deadline.
+func expired(deadline time.Time) bool {
+	return !deadline.IsZero() && time.Now().After(deadline)
+}
+
+// watchDeadline devuelve un flag que se marca al vencer el deadline, para
+// que los loops de scoring lo consulten por candidato sin llamar a time.Now.
+// stop libera el timer.
+func watchDeadline(deadline time.Time) (done *atomic.Bool, stop func()) {
+	done = new(atomic.Bool)
+	if deadline.IsZero() {
+		return done, func() {}
+	}
+	if expired(deadline) {
+		done.Store(true)
+		return done, func() {}
+	}
+	t := time.AfterFunc(time.Until(deadline), func() { done.Store(true) })
+	return done, func() { t.Stop() }
+}
+
+type scored struct {
+	id  int
+	sim float64
+}
+
+// worse ordena por similitud y, a igual similitud, por ID descendente, así el
+// top-k no depende del orden en que las goroutines recorren los candidatos.
+func worse(a, b scored) bool {
+	if a.sim != b.sim {
+		return a.sim < b.sim
+	}
+	return a.id > b.id
+}
+
+// topKHeap es un min-heap con los k mejores candidatos vistos: la raíz es el
+// peor de ellos y se reemplaza cuando llega uno mejor.
+type topKHeap []scored
+
+func (h topKHeap) Len() int            { return len(h) }
+func (h topKHeap) Less(i, j int) bool  { return worse(h[i], h[j]) }
+func (h topKHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *topKHeap) Push(x interface{}) { *h = append(*h, x.(scored)) }
+func (h *topKHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
+
+func (h *topKHeap) offer(s scored, k int) {
+	if h.Len() < k {
+		heap.Push(h, s)
+		return
+	}
+	if k > 0 && worse((*h)[0], s) {
+		(*h)[0] = s
+		heap.Fix(h, 0)
+	}
+}
+
+// mergeTopK junta los heaps de cada goroutine y devuelve los k mejores
+// ordenados por similitud descendente.
+func mergeTopK(heaps []topKHeap, k int) []types.Neighbor {
+	if k < 0 {
+		k = 0
+	}
+	all := make([]scored, 0, len(heaps)*k)
+	for _, h := range heaps {
+		all = append(all, h...)
+	}
+	sort.Slice(all, func(i, j int) bool { return worse(all[j], all[i]) })
+	if len(all) > k {
+		all = all[:k]
+	}
+
+	neighbors := make([]types.Neighbor, len(all))
+	for i, s := range all {
+		neighbors[i] = types.Neighbor{ID: strconv.Itoa(s.id), Similarity: s.sim}
+	}
+	return neighbors
+}
+
+// splitCandidates reparte los índices [0, n) en a lo sumo pool rangos
+// contiguos de tamaño similar.
+func splitCandidates(n, pool int) [][2]int {
+	if pool < 1 {
+		pool = 1
+	}
+	if pool > n {
+		pool = n
+	}
+	parts := make([][2]int, 0, pool)
+	for p := 0; p < pool; p++ {
+		parts = append(parts, [2]int{p * n / pool, (p + 1) * n / pool})
+	}
+	return parts
+}
+
+// scoreTarget devuelve los k candidatos más similares al usuario objetivo.
+// Los candidatos se reparten entre pool goroutines, cada una con su propio
+// top-k, y al final se combinan. Si vence el deadline se deja de recorrer
+// candidatos y se devuelve el top-k de los ya puntuados con partial=true.
+func scoreTarget(sc scorer, targetID int, target *sparse.Vector, block *CandidateBlock, k int, deadline time.Time, pool int) (top []types.Neighbor, partial bool) {
+	parts := splitCandidates(block.Len(), pool)
+	heaps := make([]topKHeap, len(parts))
+	var timedOut atomic.Bool
+	deadlineHit, stop := watchDeadline(deadline)
+	defer stop()
+
+	var wg sync.WaitGroup
+	for p, r := range parts {
+		wg.Add(1)
+		go func(p int, r [2]int) {
+			defer wg.Done()
+			for i := r[0]; i < r[1]; i++ {
+				if deadlineHit.Load() {
+					timedOut.Store(true)
+					return
+				}
+				candidateID := block.ids[i]
+				if candidateID == targetID {
+					continue
+				}
+				sim := sc.score(target, &block.vecs[i])
+				if sim > 0 { // Solo guardamos si hay alguna similitud positiva (opcional)
+					heaps[p].offer(scored{id: candidateID, sim: sim}, k)
+				}
+			}
+		}(p, r)
+	}
+	wg.Wait()
+
+	return mergeTopK(heaps, k), timedOut.Load()
+}
+
+// scoreBatch puntúa todos los objetivos de un lote en una sola pasada sobre
+// los candidatos: cada candidato se recorre una vez y se compara contra todos
+// los objetivos mientras está en caché. Reparte los candidatos y respeta el
+// deadline igual que scoreTarget.
+func scoreBatch(sc scorer, targets []types.Target, block *CandidateBlock, k int, deadline time.Time, pool int) (out []types.TargetNeighbors, partial bool) {
+	vecs := make([]sparse.Vector, len(targets))
+	for t, target := range targets {
+		vecs[t] = sparse.FromMap(target.Ratings)
+	}
+
+	parts := splitCandidates(block.Len(), pool)
+	heaps := make([][]topKHeap, len(parts)) // goroutine -> objetivo -> top-k
+	var timedOut atomic.Bool
+	deadlineHit, stop := watchDeadline(deadline)
+	defer stop()
+
+	var wg sync.WaitGroup
+	for p, r := range parts {
+		heaps[p] = make([]topKHeap, len(targets))
+		wg.Add(1)
+		go func(perTarget []topKHeap, r [2]int) {
+			defer wg.Done()
+			for i := r[0]; i < r[1]; i++ {
+				if deadlineHit.Load() {
+					timedOut.Store(true)
+					return
+				}
+				candidateID := block.ids[i]
+				for t, target := range targets {
+					if candidateID == target.UserID {
+						continue
+					}
+					sim := sc.score(&vecs[t], &block.vecs[i])
+					if sim > 0 {
+						perTarget[t].offer(scored{id: candidateID, sim: sim}, k)
+					}
+				}
+			}
+		}(heaps[p], r)
+	}
+	wg.Wait()
+
+	out = make([]types.TargetNeighbors, len(targets))
+	for t, target := range targets {
+		perGoroutine := make([]topKHeap, len(heaps))
+		for p := range heaps {
+			perGoroutine[p] = heaps[p][t]
+		}
+		out[t] = types.TargetNeighbors{
+			UserID:    target.UserID,
+			Neighbors: mergeTopK(perGoroutine, k),
+		}
+	}
+	return out, timedOut.Load()
+}
+
+// ScoreUser puntúa un usuario objetivo contra los candidatos de block con la
+// métrica de scoring y pool goroutines. Lo usan el benchmark y el modo local.
+func ScoreUser(scoring types.Scoring, targetID int, target map[int]float64, block *CandidateBlock, k, pool int) ([]types.Neighbor, error) {
+	sc, err := newScorer(scoring)
+	if err != nil {
+		return nil, err
+	}
+	if k < 0 {
+		k = 0
+	}
+	vec := sparse.FromMap(target)
+	top, _ := scoreTarget(sc, targetID, &vec, block, k, time.Time{}, pool)
+	return top, nil
+}
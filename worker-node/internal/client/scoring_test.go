@@ -0,0 +1,82 @@
+package client
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"goflix/pkg/types"
+	"goflix/worker-node/internal/sparse"
+)
+
+// syntheticRatings arma users usuarios con perUser ratings al azar sobre
+// movies películas. La semilla fija deja el mismo dataset en cada corrida.
+func syntheticRatings(users, movies, perUser int) map[int]map[int]float64 {
+	rng := rand.New(rand.NewSource(1))
+	ratings := make(map[int]map[int]float64, users)
+	for u := 1; u <= users; u++ {
+		m := make(map[int]float64, perUser)
+		for len(m) < perUser {
+			m[1+rng.Intn(movies)] = float64(1 + rng.Intn(5))
+		}
+		ratings[u] = m
+	}
+	return ratings
+}
+
+// el top-k no depende de cuántas goroutines se repartan los candidatos
+func TestScoreTargetPoolIndependent(t *testing.T) {
+	ratings := syntheticRatings(500, 100, 20)
+	block := NewCandidateBlock(ratings)
+	target := sparse.FromMap(ratings[1])
+	sc, err := newScorer(types.Scoring{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, partial := scoreTarget(sc, 1, &target, block, 25, time.Time{}, 1)
+	if partial || len(want) != 25 {
+		t.Fatalf("pool=1: %d vecinos, partial=%v", len(want), partial)
+	}
+	for _, pool := range []int{2, 3, 7, 64, 1000} {
+		got, _ := scoreTarget(sc, 1, &target, block, 25, time.Time{}, pool)
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("pool=%d: top-k distinto al de pool=1", pool)
+		}
+	}
+}
+
+func BenchmarkScoreTarget(b *testing.B) {
+	ratings := syntheticRatings(20000, 2000, 60)
+	block := NewCandidateBlock(ratings)
+	target := sparse.FromMap(ratings[1])
+	sc, err := newScorer(types.Scoring{Sim: types.SimPearson, MinOverlap: 3, Significance: 50})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, pool := range []int{1, 2, 4, 8, 16} {
+		b.Run(fmt.Sprintf("pool=%d", pool), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				scoreTarget(sc, 1, &target, block, 30, time.Time{}, pool)
+			}
+			b.ReportMetric(float64(block.Len())*float64(b.N)/b.Elapsed().Seconds(), "candidates/s")
+		})
+	}
+}
+
+func TestScoreUserNonPositiveK(t *testing.T) {
+	ratings := syntheticRatings(50, 20, 5)
+	block := NewCandidateBlock(ratings)
+	for _, k := range []int{0, -1, -20} {
+		got, err := ScoreUser(types.Scoring{}, 1, ratings[1], block, k, 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 0 {
+			t.Fatalf("k=%d: %d vecinos, want 0", k, len(got))
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package client
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"goflix/pkg/styles"
+	"goflix/pkg/types"
+	"goflix/worker-node/internal/sparse"
+	"sort"
+	"sync"
+)
+
+// CandidateBlock son los candidatos de un shard ya convertidos a vectores
+// dispersos, ordenados por userID. Se decodifican una vez al recibir el
+// shard y se reutilizan en todos los TASK que lo referencian.
+type CandidateBlock struct {
+	ids  []int
+	vecs []sparse.Vector
+}
+
+func NewCandidateBlock(ratings map[int]map[int]float64) *CandidateBlock {
+	b := &CandidateBlock{ids: make([]int, 0, len(ratings))}
+	for id := range ratings {
+		b.ids = append(b.ids, id)
+	}
+	sort.Ints(b.ids)
+
+	b.vecs = make([]sparse.Vector, len(b.ids))
+	for i, id := range b.ids {
+		b.vecs[i] = sparse.FromMap(ratings[id])
+	}
+	return b
+}
+
+func (b *CandidateBlock) Len() int {
+	if b == nil {
+		return 0
+	}
+	return len(b.ids)
+}
+
+// defaultShardCacheSize es la cantidad de shards que el worker mantiene
+// decodificados si no se configura otra.
+const defaultShardCacheSize = 64
+
+// shardCache es una caché LRU de los shards recibidos con LOAD_SHARD, con
+// clave el hash de su contenido. Como el hash cambia cuando cambian los datos,
+// no hace falta invalidarla al cambiar de época: los shards viejos dejan de
+// usarse y salen por LRU. Además de la cantidad de shards se limita la
+// memoria estimada de todos juntos, para que la caché entera respete el
+// presupuesto del worker y no solo cada LOAD_SHARD por separado.
+type shardCache struct {
+	mu    sync.Mutex
+	ll    *list.List               // frente = usado más recientemente
+	items map[string]*list.Element // hash -> elemento con *cachedShard
+	// rejected son los shards que no entraban en el presupuesto de memoria;
+	// los TASK que los referencian se rechazan en vez de pedir el reenvío
+	rejected map[string]bool
+	bytes    int64 // memoria estimada de los shards en caché
+	hits     uint64
+	misses   uint64
+}
+
+type cachedShard struct {
+	hash  string
+	block *CandidateBlock
+	size  int64 // memoria estimada del bloque decodificado
+}
+
+func (c *shardCache) get(hash string) (*CandidateBlock, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[hash]; ok {
+		c.ll.MoveToFront(e)
+		c.hits++
+		return e.Value.(*cachedShard).block, true
+	}
+	c.misses++
+	return nil, false
+}
+
+// put guarda el bloque, que ocupa size bytes, y descarta los menos usados
+// mientras se supere capacity shards o maxBytes de memoria (0 = sin límite).
+// El bloque recién guardado nunca se descarta: el TASK que sigue lo necesita.
+func (c *shardCache) put(hash string, block *CandidateBlock, size int64, capacity int, maxBytes int64) {
+	if capacity < 1 {
+		capacity = defaultShardCacheSize
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.items == nil {
+		c.ll = list.New()
+		c.items = make(map[string]*list.Element)
+	}
+	if e, ok := c.items[hash]; ok {
+		cs := e.Value.(*cachedShard)
+		c.bytes += size - cs.size
+		cs.block, cs.size = block, size
+		c.ll.MoveToFront(e)
+	} else {
+		c.items[hash] = c.ll.PushFront(&cachedShard{hash: hash, block: block, size: size})
+		c.bytes += size
+	}
+	for c.ll.Len() > 1 && (c.ll.Len() > capacity || (maxBytes > 0 && c.bytes > maxBytes)) {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		cs := oldest.Value.(*cachedShard)
+		delete(c.items, cs.hash)
+		c.bytes -= cs.size
+	}
+}
+
+func (c *shardCache) reject(hash string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.rejected == nil {
+		c.rejected = make(map[string]bool)
+	}
+	c.rejected[hash] = true
+}
+
+func (c *shardCache) isRejected(hash string) bool {
+	if hash == "" {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rejected[hash]
+}
+
+// stats devuelve los aciertos, fallos y shards en caché.
+func (c *shardCache) stats() (hits, misses uint64, entries int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses, len(c.items)
+}
+
+// loadShard decodifica un shard y lo guarda en la caché. Un shard que no
+// entra en el presupuesto de memoria no se decodifica.
+func (wc *WorkerClient) loadShard(data json.RawMessage) error {
+	if !wc.fitsBudget(len(data)) {
+		var header struct {
+			ShardID int    `json:"shard_id"`
+			Hash    string `json:"hash"`
+		}
+		if err := json.Unmarshal(data, &header); err != nil {
+			return err
+		}
+		wc.shards.reject(header.Hash)
+		styles.PrintFS("error", fmt.Sprintf("[WORKER] Shard %d de %d bytes supera el presupuesto de memoria, descartado", header.ShardID, len(data)))
+		return nil
+	}
+
+	var shard types.ShardData
+	if err := json.Unmarshal(data, &shard); err != nil {
+		return err
+	}
+	if shard.Hash == "" {
+		return fmt.Errorf("shard %d sin hash", shard.ShardID)
+	}
+
+	ratings := 0
+	for _, r := range shard.Ratings {
+		ratings += len(r)
+	}
+	wc.shards.put(shard.Hash, NewCandidateBlock(shard.Ratings), types.RatingsMemory(ratings), wc.ShardCacheSize, wc.MemoryBudget)
+
+	styles.PrintFS("info", fmt.Sprintf("[WORKER] Shard %d cargado (época %s, hash %s, %d usuarios)", shard.ShardID, shard.Epoch, shard.Hash, len(shard.Ratings)))
+	return nil
+}
+
+// candidates devuelve los candidatos de la tarea: los que trae el TASK o, si
+// no trae ninguno, los del shard en caché con el hash referenciado. Si no
+// está, el coordinador reenvía el shard completo.
+func (wc *WorkerClient) candidates(task *types.Task) (*CandidateBlock, bool) {
+	if len(task.CandidateRatings) > 0 || task.ShardHash == "" {
+		return NewCandidateBlock(task.CandidateRatings), true
+	}
+	return wc.shards.get(task.ShardHash)
+}
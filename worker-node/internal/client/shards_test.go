@@ -0,0 +1,71 @@
+package client
+
+import (
+	"reflect"
+	"testing"
+)
+
+// cached devuelve los hashes en caché, del más al menos usado.
+func cached(c *shardCache) []string {
+	var hashes []string
+	for e := c.ll.Front(); e != nil; e = e.Next() {
+		hashes = append(hashes, e.Value.(*cachedShard).hash)
+	}
+	return hashes
+}
+
+func TestShardCacheLimits(t *testing.T) {
+	type put struct {
+		hash string
+		size int64
+	}
+	tests := []struct {
+		name      string
+		capacity  int
+		maxBytes  int64
+		puts      []put
+		want      []string
+		wantBytes int64
+	}{
+		{"sin límites", 10, 0, []put{{"a", 100}, {"b", 100}, {"c", 100}}, []string{"c", "b", "a"}, 300},
+		{"por cantidad", 2, 0, []put{{"a", 100}, {"b", 100}, {"c", 100}}, []string{"c", "b"}, 200},
+		{"por memoria", 10, 250, []put{{"a", 100}, {"b", 100}, {"c", 100}}, []string{"c", "b"}, 200},
+		// un shard grande desaloja a varios chicos
+		{"shard grande", 10, 300, []put{{"a", 100}, {"b", 100}, {"c", 100}, {"d", 250}}, []string{"d"}, 250},
+		// el último shard se conserva aunque solo no entre
+		{"mayor que el presupuesto", 10, 100, []put{{"a", 50}, {"b", 200}}, []string{"b"}, 200},
+		// volver a guardar un hash actualiza su tamaño y lo pasa al frente
+		{"reemplazo", 10, 300, []put{{"a", 100}, {"b", 100}, {"a", 200}}, []string{"a", "b"}, 300},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c shardCache
+			for _, p := range tt.puts {
+				c.put(p.hash, &CandidateBlock{}, p.size, tt.capacity, tt.maxBytes)
+			}
+			if got := cached(&c); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("caché = %v, want %v", got, tt.want)
+			}
+			if c.bytes != tt.wantBytes {
+				t.Fatalf("bytes = %d, want %d", c.bytes, tt.wantBytes)
+			}
+		})
+	}
+}
+
+func TestShardCacheLRU(t *testing.T) {
+	var c shardCache
+	c.put("a", &CandidateBlock{}, 100, 10, 200)
+	c.put("b", &CandidateBlock{}, 100, 10, 200)
+	if _, ok := c.get("a"); !ok {
+		t.Fatal("a no está en caché")
+	}
+	// b es el menos usado: sale al llegar c
+	c.put("c", &CandidateBlock{}, 100, 10, 200)
+	if _, ok := c.get("b"); ok {
+		t.Fatal("b sigue en caché")
+	}
+	if got := cached(&c); !reflect.DeepEqual(got, []string{"c", "a"}) {
+		t.Fatalf("caché = %v", got)
+	}
+}
@@ -0,0 +1,133 @@
+package client
+
+import (
+	"fmt"
+	"math"
+
+	"goflix/pkg/types"
+	"goflix/worker-node/internal/sparse"
+)
+
+// similarityFunc calcula la similitud entre dos vectores a partir de los
+// acumulados de las películas que ambos calificaron.
+type similarityFunc func(a, b *sparse.Vector, co sparse.CoStats) float64
+
+// varianceEpsilon evita dividir por varianzas que son 0 salvo por redondeo.
+const varianceEpsilon = 1e-9
+
+// scorer aplica la métrica y los ajustes pedidos en el TASK.
+type scorer struct {
+	sim          similarityFunc
+	shrinkage    float64
+	minOverlap   int
+	significance int
+}
+
+// ValidateScoring indica si el worker sabe puntuar con s; devuelve el mismo
+// error que recibiría un TASK con esa configuración.
+func ValidateScoring(s types.Scoring) error {
+	_, err := newScorer(s)
+	return err
+}
+
+func newScorer(s types.Scoring) (scorer, error) {
+	var fn similarityFunc
+	switch s.Sim {
+	case "", types.SimCosine:
+		fn = cosineSimilarity
+	case types.SimPearson:
+		fn = pearsonSimilarity
+	case types.SimAdjustedCosine:
+		fn = adjustedCosineSimilarity
+	case types.SimJaccard:
+		fn = jaccardSimilarity
+	case types.SimEuclidean:
+		fn = euclideanSimilarity
+	default:
+		return scorer{}, fmt.Errorf("métrica de similitud desconocida: %q", s.Sim)
+	}
+	return scorer{
+		sim:          fn,
+		shrinkage:    s.Shrinkage,
+		minOverlap:   s.MinOverlap,
+		significance: s.Significance,
+	}, nil
+}
+
+// score devuelve la similitud entre a y b, siendo n las películas en común:
+// 0 si n < minOverlap, amortiguada por min(n, N)/N con significance N y
+// multiplicada por n/(n+λ) con shrinkage λ.
+func (s scorer) score(a, b *sparse.Vector) float64 {
+	co := sparse.Overlap(a, b)
+	overlap := co.N
+	if overlap < s.minOverlap {
+		return 0
+	}
+	sim := s.sim(a, b, co)
+	if s.significance > 0 && overlap < s.significance {
+		sim *= float64(overlap) / float64(s.significance)
+	}
+	if s.shrinkage > 0 {
+		sim *= float64(overlap) / (float64(overlap) + s.shrinkage)
+	}
+	return sim
+}
+
+func cosineSimilarity(a, b *sparse.Vector, co sparse.CoStats) float64 {
+	if a.Norm() == 0 || b.Norm() == 0 {
+		return 0
+	}
+	return co.Dot / (a.Norm() * b.Norm())
+}
+
+// pearsonSimilarity es la correlación de Pearson sobre las películas en
+// común, centrando con la media de esas mismas películas.
+func pearsonSimilarity(a, b *sparse.Vector, co sparse.CoStats) float64 {
+	if co.N < 2 {
+		return 0
+	}
+	n := float64(co.N)
+	num := co.Dot - co.SumA*co.SumB/n
+	denA := co.SqA - co.SumA*co.SumA/n
+	denB := co.SqB - co.SumB*co.SumB/n
+	if denA < varianceEpsilon || denB < varianceEpsilon {
+		return 0
+	}
+	return num / (math.Sqrt(denA) * math.Sqrt(denB))
+}
+
+// adjustedCosineSimilarity es el coseno de los vectores centrados en la media
+// de todos los ratings de cada usuario: a diferencia de Pearson, las normas
+// incluyen también las películas que solo calificó uno de los dos.
+func adjustedCosineSimilarity(a, b *sparse.Vector, co sparse.CoStats) float64 {
+	if a.CenteredNorm() < varianceEpsilon || b.CenteredNorm() < varianceEpsilon {
+		return 0
+	}
+	meanA, meanB := a.Mean(), b.Mean()
+	// Σ (a − μa)(b − μb) sobre las películas en común
+	num := co.Dot - meanB*co.SumA - meanA*co.SumB + float64(co.N)*meanA*meanB
+	return num / (a.CenteredNorm() * b.CenteredNorm())
+}
+
+// jaccardSimilarity compara solo qué películas calificó cada usuario, sin
+// mirar los ratings.
+func jaccardSimilarity(a, b *sparse.Vector, co sparse.CoStats) float64 {
+	union := a.Len() + b.Len() - co.N
+	if union == 0 {
+		return 0
+	}
+	return float64(co.N) / float64(union)
+}
+
+// euclideanSimilarity transforma la distancia euclídea sobre las películas en
+// común a (0, 1]: 1 / (1 + d). Sin películas en común la similitud es 0.
+func euclideanSimilarity(a, b *sparse.Vector, co sparse.CoStats) float64 {
+	if co.N == 0 {
+		return 0
+	}
+	sq := co.SqA + co.SqB - 2*co.Dot // Σ (a − b)²
+	if sq < 0 {
+		sq = 0
+	}
+	return 1 / (1 + math.Sqrt(sq))
+}
@@ -0,0 +1,153 @@
+package client
+
+import (
+	"math"
+	"testing"
+
+	"goflix/pkg/types"
+	"goflix/worker-node/internal/sparse"
+)
+
+const simTolerance = 1e-9
+
+func vec(m map[int]float64) *sparse.Vector {
+	v := sparse.FromMap(m)
+	return &v
+}
+
+// Vectores de los casos. Los valores esperados se calcularon a mano:
+//
+//	userA, userB: películas 1..3 en común; (5, 3, 4) y (4, 2, 5)
+//	userAx, userBx: como userA y userB más una película que solo calificó
+//	                cada uno (4→1 y 5→3)
+var (
+	userA  = map[int]float64{1: 5, 2: 3, 3: 4}
+	userB  = map[int]float64{1: 4, 2: 2, 3: 5}
+	userAx = map[int]float64{1: 5, 2: 3, 3: 4, 4: 1}
+	userBx = map[int]float64{1: 4, 2: 2, 3: 5, 5: 3}
+
+	disjointA = map[int]float64{1: 5, 2: 3}
+	disjointB = map[int]float64{3: 4, 4: 1}
+	flat      = map[int]float64{1: 3, 2: 3, 3: 3} // varianza 0
+	single    = map[int]float64{1: 5, 4: 3}       // solo la película 1 en común con userB
+	ascending = map[int]float64{1: 1, 2: 2, 3: 3}
+	inverse   = map[int]float64{1: 3, 2: 2, 3: 1}
+)
+
+func TestSimilarityMetrics(t *testing.T) {
+	tests := []struct {
+		name string
+		sim  string
+		x, y map[int]float64
+		want float64
+	}{
+		// coseno: Σab / (||a||·||b||) con las normas de todos los ratings
+		{"cosine", types.SimCosine, userA, userB, 46 / math.Sqrt(50*45)},
+		{"cosine/partial", types.SimCosine, userAx, userBx, 46 / math.Sqrt(51*54)},
+		{"cosine/identical", types.SimCosine, userA, userA, 1},
+		{"cosine/no-overlap", types.SimCosine, disjointA, disjointB, 0},
+		{"cosine/flat", types.SimCosine, flat, userB, 33 / math.Sqrt(27*45)},
+
+		// pearson: medias 4 y 11/3; desvíos (1, −1, 0) y (1/3, −5/3, 4/3)
+		// num = 2, Σda² = 2, Σdb² = 14/3
+		{"pearson", types.SimPearson, userA, userB, 2 / math.Sqrt(2*14.0/3)},
+		// las películas que no están en común no cuentan
+		{"pearson/partial", types.SimPearson, userAx, userBx, 2 / math.Sqrt(2*14.0/3)},
+		{"pearson/identical", types.SimPearson, userA, userA, 1},
+		{"pearson/inverse", types.SimPearson, ascending, inverse, -1},
+		{"pearson/no-overlap", types.SimPearson, disjointA, disjointB, 0},
+		{"pearson/zero-variance", types.SimPearson, flat, userB, 0},
+		{"pearson/n<2", types.SimPearson, single, userB, 0},
+
+		// coseno ajustado: medias 13/4 y 7/2 sobre todos los ratings
+		// aa − μ = (1.75, −0.25, 0.75, −2.25), ||·||² = 8.75
+		// bb − μ = (0.5, −1.5, 1.5, −0.5),     ||·||² = 5
+		// num (películas 1..3) = 0.875 + 0.375 + 1.125 = 2.375
+		{"adjusted-cosine", types.SimAdjustedCosine, userAx, userBx, 2.375 / math.Sqrt(8.75*5)},
+		{"adjusted-cosine/identical", types.SimAdjustedCosine, userA, userA, 1},
+		{"adjusted-cosine/no-overlap", types.SimAdjustedCosine, disjointA, disjointB, 0},
+		{"adjusted-cosine/zero-variance", types.SimAdjustedCosine, flat, userB, 0},
+
+		// jaccard: |a ∩ b| / |a ∪ b|
+		{"jaccard", types.SimJaccard, userAx, userBx, 3.0 / 5},
+		{"jaccard/same-items", types.SimJaccard, userA, userB, 1},
+		{"jaccard/no-overlap", types.SimJaccard, disjointA, disjointB, 0},
+
+		// euclídea: diferencias (1, 1, −1) en común, d = √3
+		{"euclidean", types.SimEuclidean, userA, userB, 1 / (1 + math.Sqrt(3))},
+		{"euclidean/partial", types.SimEuclidean, userAx, userBx, 1 / (1 + math.Sqrt(3))},
+		{"euclidean/identical", types.SimEuclidean, userA, userA, 1},
+		{"euclidean/no-overlap", types.SimEuclidean, disjointA, disjointB, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, err := newScorer(types.Scoring{Sim: tt.sim})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := sc.score(vec(tt.x), vec(tt.y))
+			if math.Abs(got-tt.want) > simTolerance {
+				t.Fatalf("score = %.12f, want %.12f", got, tt.want)
+			}
+			// todas las métricas son simétricas
+			if rev := sc.score(vec(tt.y), vec(tt.x)); math.Abs(rev-got) > simTolerance {
+				t.Fatalf("score(y, x) = %.12f, score(x, y) = %.12f", rev, got)
+			}
+		})
+	}
+}
+
+func TestScorerAdjustments(t *testing.T) {
+	cosine := 46 / math.Sqrt(50*45) // userA y userB tienen 3 películas en común
+
+	tests := []struct {
+		name    string
+		scoring types.Scoring
+		x, y    map[int]float64
+		want    float64
+	}{
+		{"none", types.Scoring{}, userA, userB, cosine},
+		{"min-overlap/met", types.Scoring{MinOverlap: 3}, userA, userB, cosine},
+		{"min-overlap/not-met", types.Scoring{MinOverlap: 4}, userA, userB, 0},
+		// min(n, N)/N = 3/6
+		{"significance", types.Scoring{Significance: 6}, userA, userB, cosine * 3 / 6},
+		{"significance/reached", types.Scoring{Significance: 3}, userA, userB, cosine},
+		// n/(n+λ) = 3/(3+3)
+		{"shrinkage", types.Scoring{Shrinkage: 3}, userA, userB, cosine * 3 / 6},
+		{"shrinkage+significance", types.Scoring{Shrinkage: 3, Significance: 6}, userA, userB, cosine * 0.5 * 0.5},
+		// jaccard 3/5 con λ = 2: 0.6 · 3/5
+		{"shrinkage/jaccard", types.Scoring{Sim: types.SimJaccard, Shrinkage: 2}, userAx, userBx, 0.36},
+		// sin películas en común el shrinkage deja la similitud en 0
+		{"shrinkage/no-overlap", types.Scoring{Shrinkage: 5}, disjointA, disjointB, 0},
+		// pearson −1 amortiguado: el signo se conserva
+		{"significance/negative", types.Scoring{Sim: types.SimPearson, Significance: 6}, ascending, inverse, -0.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, err := newScorer(tt.scoring)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := sc.score(vec(tt.x), vec(tt.y)); math.Abs(got-tt.want) > simTolerance {
+				t.Fatalf("score = %.12f, want %.12f", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewScorerUnknownMetric(t *testing.T) {
+	if _, err := newScorer(types.Scoring{Sim: "manhattan"}); err == nil {
+		t.Fatal("se esperaba error para una métrica desconocida")
+	}
+	if err := ValidateScoring(types.Scoring{Sim: "manhattan"}); err == nil {
+		t.Fatal("ValidateScoring aceptó una métrica desconocida")
+	}
+	if err := ValidateScoring(types.Scoring{Sim: types.SimPearson}); err != nil {
+		t.Fatalf("ValidateScoring(pearson) = %v", err)
+	}
+	if _, err := ScoreUser(types.Scoring{Sim: "manhattan"}, 1, userA, NewCandidateBlock(nil), 5, 1); err == nil {
+		t.Fatal("ScoreUser aceptó una métrica desconocida")
+	}
+}
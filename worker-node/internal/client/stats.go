@@ -0,0 +1,56 @@
+package client
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+
+	"goflix/pkg/types"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/shirou/gopsutil/v3/mem"
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// loadSampler mide el uso de recursos para los heartbeats. Los porcentajes de
+// CPU son relativos a la muestra anterior, así cada heartbeat reporta el uso
+// durante el último intervalo.
+type loadSampler struct {
+	proc *process.Process // nil si no se pudo abrir el proceso
+}
+
+func newLoadSampler() *loadSampler {
+	proc, err := process.NewProcess(int32(os.Getpid()))
+	if err != nil {
+		proc = nil
+	}
+	s := &loadSampler{proc: proc}
+	s.sample() // primera muestra: fija la referencia de los porcentajes
+	return s
+}
+
+// sample devuelve el uso actual. Las métricas que el sistema no expone quedan
+// en 0.
+func (s *loadSampler) sample() types.WorkerLoad {
+	load := types.WorkerLoad{Goroutines: runtime.NumGoroutine()}
+	if s.proc != nil {
+		if pct, err := s.proc.Percent(0); err == nil {
+			load.CPU = pct
+		}
+		if info, err := s.proc.MemoryInfo(); err == nil {
+			load.RSS = info.RSS
+		}
+	}
+	if pcts, err := cpu.Percent(0, false); err == nil && len(pcts) > 0 {
+		load.HostCPU = pcts[0]
+	}
+	if vm, err := mem.VirtualMemory(); err == nil {
+		load.HostMem = vm.UsedPercent
+	}
+	return load
+}
+
+// taskLabel identifica un TASK en los heartbeats y logs.
+func taskLabel(task *types.Task) string {
+	return fmt.Sprintf("%s[%d-%d]", task.JobID, task.BlockID.StartID, task.BlockID.EndID)
+}
@@ -0,0 +1,66 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// StatusHandler expone el estado del worker por HTTP:
+//   - /healthz: 200 mientras el proceso no se esté cerrando
+//   - /readyz: 200 si completó el handshake y tiene conexión con el coordinador
+//   - /metrics: métricas en formato Prometheus
+func (wc *WorkerClient) StatusHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		if wc.CurrentState() == StShuttingDown {
+			http.Error(w, "shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if !wc.ready() {
+			http.Error(w, "not ready: "+wc.CurrentState().String(), http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		wc.writeMetrics(w)
+	})
+	return mux
+}
+
+// ready indica si el worker puede recibir tareas.
+func (wc *WorkerClient) ready() bool {
+	switch wc.CurrentState() {
+	case StReady, StWorking:
+	default:
+		return false
+	}
+	wc.connMu.Lock()
+	defer wc.connMu.Unlock()
+	return wc.Conn != nil
+}
+
+// ServeStatus sirve StatusHandler en addr hasta que se cancele ctx.
+func (wc *WorkerClient) ServeStatus(ctx context.Context, addr string) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           wc.StatusHandler(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"time"
+
+	"goflix/pkg/styles"
+)
+
+const (
+	defaultMinBackoff = 500 * time.Millisecond
+	defaultMaxBackoff = 30 * time.Second
+)
+
+// Supervisor mantiene al worker conectado al coordinador: conecta, hace el
+// handshake, corre el heartbeat y el procesamiento de tareas y, si la
+// conexión se cae, los detiene y vuelve a conectar con backoff exponencial
+// con jitter. Cada paso se refleja en Client.State.
+type Supervisor struct {
+	Addr              string
+	Client            *WorkerClient
+	HeartbeatInterval time.Duration
+	MinBackoff        time.Duration // default 500ms
+	MaxBackoff        time.Duration // default 30s
+
+	// Dial abre la conexión; nil usa net.Dialer
+	Dial func(ctx context.Context, addr string) (net.Conn, error)
+}
+
+// Run conecta y reconecta hasta que se cancele ctx. Solo devuelve nil, al
+// cancelarse ctx, con el cliente en StShuttingDown.
+func (s *Supervisor) Run(ctx context.Context) error {
+	attempt := 0
+	for {
+		connected, err := s.session(ctx)
+		if ctx.Err() != nil {
+			s.Client.setState(StShuttingDown)
+			return nil
+		}
+		if connected {
+			attempt = 0 // la conexión llegó a funcionar: se reinicia el backoff
+		}
+
+		wait := s.backoff(attempt)
+		attempt++
+		styles.PrintFS("error", fmt.Sprintf("[WORKER] %v; reintentando en %v", err, wait.Round(time.Millisecond)))
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			s.Client.setState(StShuttingDown)
+			return nil
+		case <-timer.C:
+		}
+	}
+}
+
+// session corre una conexión completa. connected indica si llegó a terminar
+// el handshake; err es el motivo por el que terminó.
+func (s *Supervisor) session(ctx context.Context) (connected bool, err error) {
+	wc := s.Client
+	wc.setState(StConnecting)
+
+	conn, err := s.dial(ctx)
+	if err != nil {
+		wc.metrics.addError(errConnection)
+		wc.setState(StDisconnected)
+		return false, fmt.Errorf("error de conexión: %w", err)
+	}
+	conn = countingConn{Conn: conn, in: &wc.metrics.bytesIn, out: &wc.metrics.bytesOut}
+	defer func() {
+		conn.Close()
+		wc.connMu.Lock()
+		wc.Conn = nil
+		wc.connMu.Unlock()
+		wc.setCurrentTask(nil)
+		wc.setState(StDisconnected)
+	}()
+
+	// si se cancela ctx durante el handshake, cerrar el socket lo desbloquea
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	workerID, err := wc.HandShake(conn)
+	stop()
+	if err != nil {
+		return false, fmt.Errorf("error en el handshake: %w", err)
+	}
+	styles.PrintFS("success", fmt.Sprintf("[WORKER] Handshake completado. Worker ID asignado: %s", workerID))
+
+	sessCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	heartbeatDone := make(chan error, 1)
+	taskDone := make(chan error, 1)
+	go func() { heartbeatDone <- wc.StartHeartbeat(sessCtx, s.HeartbeatInterval) }()
+	go func() { taskDone <- wc.Process(sessCtx) }()
+
+	// la primera goroutine que termina decide el motivo; se detiene la otra
+	// cerrando el socket, que desbloquea cualquier lectura pendiente
+	select {
+	case err = <-heartbeatDone:
+		err = fmt.Errorf("heartbeat detenido: %w", err)
+		cancel()
+		conn.Close()
+		<-taskDone
+	case err = <-taskDone:
+		err = fmt.Errorf("proceso detenido: %w", err)
+		cancel()
+		conn.Close()
+		<-heartbeatDone
+	}
+	if ctx.Err() == nil {
+		wc.metrics.addError(errConnection)
+	}
+	return true, err
+}
+
+func (s *Supervisor) dial(ctx context.Context) (net.Conn, error) {
+	if s.Dial != nil {
+		return s.Dial(ctx, s.Addr)
+	}
+	var d net.Dialer
+	return d.DialContext(ctx, "tcp", s.Addr)
+}
+
+// backoff devuelve la espera antes del reintento attempt: crece al doble en
+// cada intento hasta MaxBackoff y se elige al azar en [d/2, d) para que los
+// workers no reconecten todos a la vez tras una caída del coordinador.
+func (s *Supervisor) backoff(attempt int) time.Duration {
+	lo, hi := s.MinBackoff, s.MaxBackoff
+	if lo <= 0 {
+		lo = defaultMinBackoff
+	}
+	if hi < lo {
+		hi = defaultMaxBackoff
+	}
+	if hi < lo {
+		hi = lo
+	}
+
+	d := lo
+	for i := 0; i < attempt && d < hi; i++ {
+		d *= 2
+	}
+	if d > hi {
+		d = hi
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)+1))
+}
@@ -0,0 +1,374 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"goflix/pkg/tcp"
+	"goflix/pkg/types"
+)
+
+type transition struct{ from, to ClientState }
+
+// recordStates registra cada transición de estado del cliente.
+func recordStates(wc *WorkerClient) <-chan transition {
+	ch := make(chan transition, 64)
+	wc.OnStateChange = func(from, to ClientState) { ch <- transition{from, to} }
+	return ch
+}
+
+// expectStates espera las transiciones want en orden.
+func expectStates(t *testing.T, ch <-chan transition, want ...ClientState) {
+	t.Helper()
+	for _, to := range want {
+		select {
+		case tr := <-ch:
+			if tr.to != to {
+				t.Fatalf("transición %s -> %s, want -> %s", tr.from, tr.to, to)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout esperando -> %s", to)
+		}
+	}
+}
+
+// fakeCoordinator es el extremo del coordinador de una conexión net.Pipe.
+type fakeCoordinator struct {
+	t    *testing.T
+	conn net.Conn
+}
+
+func (fc *fakeCoordinator) read(wantType string) types.Message {
+	fc.t.Helper()
+	for {
+		msg, err := tcp.ReadMessage(fc.conn)
+		if err != nil {
+			fc.t.Errorf("coordinador: leyendo %s: %v", wantType, err)
+			return msg
+		}
+		if msg.Type == "HEARTBEAT" {
+			continue
+		}
+		if msg.Type != wantType {
+			fc.t.Errorf("coordinador: recibió %s, want %s", msg.Type, wantType)
+		}
+		return msg
+	}
+}
+
+func (fc *fakeCoordinator) send(msgType string, v interface{}) {
+	fc.t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		fc.t.Error(err)
+		return
+	}
+	if err := tcp.WriteMessage(fc.conn, types.Message{Type: msgType, Data: data}); err != nil {
+		fc.t.Errorf("coordinador: enviando %s: %v", msgType, err)
+	}
+}
+
+// handshake responde el HELLO con un ACK y devuelve el HELLO recibido.
+func (fc *fakeCoordinator) handshake(workerID string) types.Hello {
+	var hello types.Hello
+	json.Unmarshal(fc.read("HELLO").Data, &hello)
+	fc.send("ACK", map[string]string{"worker_id": workerID})
+	return hello
+}
+
+// drain descarta lo que siga enviando el worker hasta que se cierre la conexión.
+func (fc *fakeCoordinator) drain() {
+	for {
+		if _, err := tcp.ReadMessage(fc.conn); err != nil {
+			return
+		}
+	}
+}
+
+// dialer entrega a Supervisor.Dial, en orden, una conexión por cada función
+// de coordinador; nil simula un error de conexión.
+type dialer struct {
+	t        *testing.T
+	mu       sync.Mutex
+	sessions []func(fc *fakeCoordinator)
+	dials    []time.Time
+	wg       sync.WaitGroup
+}
+
+var errDialRefused = errors.New("connection refused")
+
+func (d *dialer) dial(ctx context.Context, addr string) (net.Conn, error) {
+	d.mu.Lock()
+	d.dials = append(d.dials, time.Now())
+	if len(d.sessions) == 0 {
+		d.mu.Unlock()
+		// sin más sesiones previstas: esperar a que el test cancele
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	run := d.sessions[0]
+	d.sessions = d.sessions[1:]
+	d.mu.Unlock()
+	if run == nil {
+		return nil, errDialRefused
+	}
+
+	worker, coordinator := net.Pipe()
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		defer coordinator.Close()
+		run(&fakeCoordinator{t: d.t, conn: coordinator})
+	}()
+	return worker, nil
+}
+
+func (d *dialer) dialTimes() []time.Time {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]time.Time(nil), d.dials...)
+}
+
+func newTestSupervisor(d *dialer, wc *WorkerClient, minBackoff time.Duration) *Supervisor {
+	return &Supervisor{
+		Addr:              "coordinator:9000",
+		Client:            wc,
+		HeartbeatInterval: time.Hour, // sin HEARTBEAT durante el test
+		MinBackoff:        minBackoff,
+		MaxBackoff:        10 * time.Second,
+		Dial:              d.dial,
+	}
+}
+
+func TestSupervisorLifecycle(t *testing.T) {
+	wc := NewClient()
+	wc.Concurrency = 2
+	states := recordStates(wc)
+
+	taskDone := make(chan types.Result, 1)
+	ready := make(chan struct{})
+	d := &dialer{t: t}
+	d.sessions = []func(fc *fakeCoordinator){
+		// sesión 1: handshake, un TASK y el coordinador corta la conexión
+		func(fc *fakeCoordinator) {
+			hello := fc.handshake("w-1")
+			if hello.Concurrency != 2 || len(hello.Algorithms) == 0 {
+				t.Errorf("HELLO = %+v", hello)
+			}
+			fc.read("PULL")
+			fc.send("TASK", types.Task{
+				JobID:            "job-1",
+				BlockID:          types.Block{StartID: 2, EndID: 3},
+				TargetID:         1,
+				TargetRatings:    map[int]float64{10: 5, 20: 3},
+				CandidateRatings: map[int]map[int]float64{2: {10: 5, 20: 3}, 3: {10: 1}},
+				K:                5,
+			})
+			var result types.Result
+			json.Unmarshal(fc.read("RESULT").Data, &result)
+			taskDone <- result
+			fc.read("PULL")
+		},
+		// sesión 2: la conexión es rechazada
+		nil,
+		// sesión 3: queda lista hasta que se cancela el contexto
+		func(fc *fakeCoordinator) {
+			fc.handshake("w-2")
+			close(ready)
+			fc.drain()
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() { runErr <- newTestSupervisor(d, wc, time.Millisecond).Run(ctx) }()
+
+	expectStates(t, states, StConnecting, StHandshaking, StReady, StWorking, StReady)
+	select {
+	case result := <-taskDone:
+		if result.JobID != "job-1" || result.Error != "" || len(result.Neighbors) == 0 {
+			t.Fatalf("RESULT = %+v", result)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout esperando el RESULT")
+	}
+
+	// la conexión se cae: se reconecta, falla una vez y vuelve a conectar
+	expectStates(t, states,
+		StDisconnected,
+		StConnecting, StDisconnected,
+		StConnecting, StHandshaking, StReady)
+	<-ready
+	if wc.ID != "w-2" {
+		t.Fatalf("ID = %q tras reconectar, want w-2", wc.ID)
+	}
+	if !wc.ready() {
+		t.Fatal("ready() = false con la sesión establecida")
+	}
+
+	cancel()
+	expectStates(t, states, StDisconnected, StShuttingDown)
+	select {
+	case err := <-runErr:
+		if err != nil {
+			t.Fatalf("Run = %v, want nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run no terminó al cancelar el contexto")
+	}
+	if st := wc.CurrentState(); st != StShuttingDown {
+		t.Fatalf("estado final %s, want shutting-down", st)
+	}
+	d.wg.Wait()
+}
+
+func TestSupervisorShutdownDuringHandshake(t *testing.T) {
+	wc := NewClient()
+	states := recordStates(wc)
+
+	helloSeen := make(chan struct{})
+	d := &dialer{t: t}
+	d.sessions = []func(fc *fakeCoordinator){
+		func(fc *fakeCoordinator) {
+			fc.read("HELLO")
+			close(helloSeen) // nunca responde el ACK
+			fc.drain()
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() { runErr <- newTestSupervisor(d, wc, time.Millisecond).Run(ctx) }()
+
+	expectStates(t, states, StConnecting, StHandshaking)
+	<-helloSeen
+	cancel()
+	select {
+	case err := <-runErr:
+		if err != nil {
+			t.Fatalf("Run = %v, want nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run no terminó al cancelar durante el handshake")
+	}
+	if st := wc.CurrentState(); st != StShuttingDown {
+		t.Fatalf("estado final %s, want shutting-down", st)
+	}
+	d.wg.Wait()
+}
+
+func TestSupervisorBackoffResetsAfterSession(t *testing.T) {
+	const minBackoff = 20 * time.Millisecond
+
+	wc := NewClient()
+	closed := make(chan time.Time, 1)
+	d := &dialer{t: t}
+	// cuatro fallos seguidos (esperas de hasta 20, 40, 80 y 160ms), una
+	// sesión que conecta y otro fallo
+	d.sessions = []func(fc *fakeCoordinator){nil, nil, nil, nil,
+		func(fc *fakeCoordinator) {
+			fc.handshake("w-1")
+			fc.read("PULL")
+			closed <- time.Now()
+		},
+		nil,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go newTestSupervisor(d, wc, minBackoff).Run(ctx)
+
+	deadline := time.After(10 * time.Second)
+	for len(d.dialTimes()) < 7 {
+		select {
+		case <-deadline:
+			t.Fatalf("solo %d intentos de conexión", len(d.dialTimes()))
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+	cancel()
+
+	dials := d.dialTimes()
+	// entre fallos la espera se duplica: el intento i espera al menos
+	// minBackoff·2^i / 2
+	for i := 1; i < 5; i++ {
+		gap := dials[i].Sub(dials[i-1])
+		if min := minBackoff << (i - 1) / 2; gap < min {
+			t.Errorf("espera antes del intento %d = %v, want >= %v", i+1, gap, min)
+		}
+	}
+	// tras una sesión que conectó se vuelve a la espera mínima: sin reinicio
+	// el intento 6 esperaría al menos 160ms
+	gap := dials[5].Sub(<-closed)
+	if gap >= minBackoff<<4/2 {
+		t.Errorf("espera tras la sesión = %v, el backoff no se reinició", gap)
+	}
+	d.wg.Wait()
+}
+
+func TestSupervisorBackoffBounds(t *testing.T) {
+	s := &Supervisor{MinBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	for attempt := 0; attempt < 10; attempt++ {
+		d := s.MinBackoff << attempt
+		if d > s.MaxBackoff || d <= 0 {
+			d = s.MaxBackoff
+		}
+		for i := 0; i < 50; i++ {
+			if got := s.backoff(attempt); got < d/2 || got > d {
+				t.Fatalf("backoff(%d) = %v, want en [%v, %v]", attempt, got, d/2, d)
+			}
+		}
+	}
+
+	// sin configurar se usan los defaults
+	var zero Supervisor
+	if got := zero.backoff(0); got < defaultMinBackoff/2 || got > defaultMinBackoff {
+		t.Fatalf("backoff(0) sin configurar = %v", got)
+	}
+	if got := zero.backoff(100); got < defaultMaxBackoff/2 || got > defaultMaxBackoff {
+		t.Fatalf("backoff(100) sin configurar = %v", got)
+	}
+}
+
+func TestInvalidTaskReturnsResult(t *testing.T) {
+	wc := NewClient()
+	got := make(chan types.Result, 1)
+	d := &dialer{t: t}
+	d.sessions = []func(fc *fakeCoordinator){
+		func(fc *fakeCoordinator) {
+			fc.handshake("w-1")
+			fc.read("PULL")
+			// k no es un número: el TASK no se puede decodificar
+			fc.send("TASK", map[string]interface{}{
+				"job_id":   "job-1",
+				"block_id": types.Block{StartID: 4, EndID: 7},
+				"k":        "diez",
+			})
+			var result types.Result
+			json.Unmarshal(fc.read("RESULT").Data, &result)
+			got <- result
+			fc.drain()
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go newTestSupervisor(d, wc, time.Millisecond).Run(ctx)
+
+	select {
+	case result := <-got:
+		want := types.Result{JobID: "job-1", BlockID: types.Block{StartID: 4, EndID: 7}, Error: types.ErrTaskInvalid}
+		if result.JobID != want.JobID || result.BlockID != want.BlockID || result.Error != want.Error {
+			t.Fatalf("RESULT = %+v, want %+v", result, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("el worker no respondió el TASK inválido")
+	}
+	cancel()
+	d.wg.Wait()
+}
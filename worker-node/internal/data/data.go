@@ -12,21 +12,16 @@ import (
 )
 
 const (
-	DataDir     = "../dataset/ml-latest-small"
+	DataDir     = "../dataset/ml-latest-small" // default si no se indica otro directorio
 	RatingsFile = "ratings.csv"
 	MoviesFile  = "movies.csv"
 )
 
-func mustOpen(path string) *os.File {
+func LoadRatings(path string) ([]types.Rating, error) {
 	f, err := os.Open(path)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	return f
-}
-
-func LoadRatings(path string) ([]types.Rating, error) {
-	f := mustOpen(path)
 	defer f.Close()
 
 	r := csv.NewReader(bufio.NewReader(f))
@@ -57,7 +52,10 @@ func LoadRatings(path string) ([]types.Rating, error) {
 }
 
 func LoadMovieTitles(path string) (map[int]string, error) {
-	f := mustOpen(path)
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
 	defer f.Close()
 
 	r := csv.NewReader(bufio.NewReader(f))
@@ -1,5 +1,7 @@
 package engine
 
+import "goflix/worker-node/internal/sparse"
+
 // PartialAccResult representa el resultado parcial del cálculo de similitudes
 // sobre un subconjunto de usuarios. La idea es que estos resultados se
 // agreguen/mergeen en un coordinador para luego reconstruir la matriz de
@@ -22,9 +24,13 @@ func ComputePartialSimilarities(userSubset []int, userRatings map[int]map[int]fl
 	// return:
 	//   - PartialAccResult con los mapas parciales listos para ser mergeados en el coordinador.
 
+	userVecs := make(map[int]sparse.Vector, len(userSubset))
+	for _, u := range userSubset {
+		userVecs[u] = sparse.FromMap(userRatings[u])
+	}
+
 	local := newPartialAcc()
-	buf := make([]int, 0, 64)
-	processUserBlock(local, userSubset, userRatings, buf)
+	processUserBlock(local, userSubset, userVecs)
 
 	return PartialAccResult{
 		Dot:  local.dot,
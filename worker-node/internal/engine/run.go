@@ -6,65 +6,106 @@ import (
 	"goflix/worker-node/internal/data"
 	"goflix/worker-node/internal/recommend"
 	"goflix/worker-node/internal/types"
+	"os"
 	"path/filepath"
 )
 
-func Run() {
-	fmt.Println("GoFlix Item-based CF (cosine) concurrente en un solo nodo")
+// Options configura una corrida del motor en un solo nodo.
+type Options struct {
+	DataDir string // directorio con ratings.csv y movies.csv
+	UserID  int    // usuario a recomendar; 0 = el primero del dataset
+	TopN    int    // recomendaciones a mostrar
+	Workers int    // goroutines para las similitudes; 0 = elegir con benchmark
+}
 
-	// TopKNeighborsN := 20
-	rPath := filepath.Join(data.DataDir, data.RatingsFile)
-	mPath := filepath.Join(data.DataDir, data.MoviesFile)
+// Dataset son los ratings de un directorio MovieLens ya indexados.
+type Dataset struct {
+	UserRatings map[int]map[int]float64
+	Users       []int // ordenados
+	Titles      map[int]string
+	NumRatings  int
+}
 
-	fmt.Println("Cargando ratings desde:", rPath)
-	ratings, err := data.LoadRatings(rPath)
+// LoadDataset lee ratings.csv y movies.csv de dir y construye los índices.
+func LoadDataset(dir string) (*Dataset, error) {
+	ratings, err := data.LoadRatings(filepath.Join(dir, data.RatingsFile))
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	fmt.Println("Cargando títulos desde:", mPath)
-	titles, err := data.LoadMovieTitles(mPath)
+	titles, err := data.LoadMovieTitles(filepath.Join(dir, data.MoviesFile))
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
 	// Índices (user->items, item->users)
-	fmt.Println("Construyendo índices…")
 	userRatings, _, users, _ := data.BuildIndexes(ratings)
-	fmt.Printf("Usuarios: %d, Ratings: %d\n", len(users), len(ratings))
 
-	// Benchmark de paralelismo (solo similitudes)
-	results, bestWorkers := benchmark.BenchmarkWorkers(userRatings, BuildSimilaritiesConcurrent)
-	benchmark.PrintBench(results)
-	fmt.Printf("\nMejor configuración observada: %d workers\n", bestWorkers)
+	return &Dataset{
+		UserRatings: userRatings,
+		Users:       users,
+		Titles:      titles,
+		NumRatings:  len(ratings),
+	}, nil
+}
+
+// Run calcula las similitudes ítem-ítem del dataset y muestra las
+// recomendaciones de un usuario.
+func Run(opts Options) error {
+	fmt.Println("GoFlix Item-based CF (cosine) concurrente en un solo nodo")
 
-	// Construir similitudes y vecindades con la mejor configuración
-	fmt.Println("\nReconstruyendo similitudes con la mejor configuración…")
-	sim, _ := BuildSimilaritiesConcurrent(userRatings, bestWorkers)
+	if opts.DataDir == "" {
+		opts.DataDir = data.DataDir
+	}
+	if opts.TopN <= 0 {
+		opts.TopN = 10
+	}
+	fmt.Println("Cargando dataset desde:", opts.DataDir)
+	ds, err := LoadDataset(opts.DataDir)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Usuarios: %d, Ratings: %d\n", len(ds.Users), ds.NumRatings)
+	if len(ds.Users) == 0 {
+		fmt.Println("No hay usuarios.")
+		return nil
+	}
+	userID := opts.UserID
+	if userID == 0 {
+		userID = ds.Users[0]
+	}
+	if _, ok := ds.UserRatings[userID]; !ok {
+		return fmt.Errorf("el usuario %d no tiene ratings en %s", userID, opts.DataDir)
+	}
+
+	workers := opts.Workers
+	if workers <= 0 {
+		// Benchmark de paralelismo (solo similitudes)
+		results, bestWorkers := benchmark.BenchmarkWorkers(ds.UserRatings, BuildSimilaritiesConcurrent)
+		benchmark.PrintBench(os.Stdout, results)
+		fmt.Printf("\nMejor configuración observada: %d workers\n", bestWorkers)
+		workers = bestWorkers
+	}
+
+	fmt.Printf("\nConstruyendo similitudes con %d workers…\n", workers)
+	sim, _ := BuildSimilaritiesConcurrent(ds.UserRatings, workers)
 	fmt.Printf("Ítems con vecindad calculada: %d\n", len(sim))
 
 	fmt.Printf("Seleccionando top-%d vecinos por ítem…\n", types.TopKNeighborsN)
 	nbrs := TopKNeighbors(sim, types.TopKNeighborsN)
 
-	// recomendar a un usuario
-	if len(users) == 0 {
-		fmt.Println("No hay usuarios.")
-		return
-	}
-	demoUser := users[0]
-	fmt.Printf("\nEjemplo de recomendaciones para el usuario %d\n", demoUser)
-	recs := recommend.RecommendTopN(demoUser, 10, userRatings, nbrs, titles)
+	fmt.Printf("\nRecomendaciones para el usuario %d\n", userID)
+	recs := recommend.RecommendTopN(userID, opts.TopN, ds.UserRatings, nbrs, ds.Titles)
 	if len(recs) == 0 {
-		fmt.Println("No se pudieron generar recomendaciones para el usuario de ejemplo.")
-		return
+		fmt.Println("No se pudieron generar recomendaciones para el usuario.")
+		return nil
 	}
 	fmt.Println(recommend.HumanList(recs))
 
 	// Predicción de una película candidata
-	if len(recs) > 0 {
-		item := recs[0].MovieID
-		if p, ok := recommend.PredictForUserItem(demoUser, item, userRatings, nbrs); ok {
-			fmt.Printf("Predicción para user %d sobre movie %d (%s): %.3f\n",
-				demoUser, item, titles[item], p)
-		}
+	item := recs[0].MovieID
+	if p, ok := recommend.PredictForUserItem(userID, item, ds.UserRatings, nbrs); ok {
+		fmt.Printf("Predicción para user %d sobre movie %d (%s): %.3f\n",
+			userID, item, ds.Titles[item], p)
 	}
+	return nil
 }
@@ -2,6 +2,7 @@ package engine
 
 import (
 	"container/heap"
+	"goflix/worker-node/internal/sparse"
 	"goflix/worker-node/internal/types"
 	"math"
 	"runtime"
@@ -57,42 +58,31 @@ func mergeAcc(dst, src *partialAcc) {
 	}
 }
 
-// Procesa un bloque de usuarios y acumula en un partialAcc dado. Reutiliza buf para evitar allocs por usuario.
-func processUserBlock(acc *partialAcc, users []int, userRatings map[int]map[int]float64, buf []int) []int {
+// Procesa un bloque de usuarios y acumula en un partialAcc dado. Los vectores
+// ya traen los ítems ordenados en slices contiguos, así no hay allocs por usuario.
+func processUserBlock(acc *partialAcc, users []int, userVecs map[int]sparse.Vector) {
 	for _, u := range users {
-		ru := userRatings[u]
-		if len(ru) == 0 {
+		vu := userVecs[u]
+		n := vu.Len()
+		if n == 0 {
 			continue
 		}
 
 		// normas por ítem (sum r^2)
-		for i, r := range ru {
-			acc.addNorm(i, r*r)
-		}
-
-		// items del usuario en buf[0:n]
-		n := 0
-		for i := range ru {
-			if n < len(buf) {
-				buf[n] = i
-			} else {
-				buf = append(buf, i)
-			}
-			n++
+		for a := 0; a < n; a++ {
+			r := float64(vu.Values[a])
+			acc.addNorm(int(vu.IDs[a]), r*r)
 		}
 
 		// pares de ítems co-calificados por este usuario
 		for a := 0; a < n; a++ {
-			i := buf[a]
-			ri := ru[i]
+			i := int(vu.IDs[a])
+			ri := float64(vu.Values[a])
 			for b := a + 1; b < n; b++ {
-				j := buf[b]
-				rj := ru[j]
-				acc.addDot(i, j, ri*rj)
+				acc.addDot(i, int(vu.IDs[b]), ri*float64(vu.Values[b]))
 			}
 		}
 	}
-	return buf
 }
 
 func chunkInts(all []int, size int) [][]int {
@@ -135,6 +125,7 @@ func BuildSimilaritiesConcurrent(userRatings map[int]map[int]float64, workerCoun
 		batch = 10
 	}
 	blocks := chunkInts(users, batch)
+	userVecs := sparse.FromRatings(userRatings)
 
 	// Lanza workers con su partialAcc local
 	workCh := make(chan []int)
@@ -147,9 +138,8 @@ func BuildSimilaritiesConcurrent(userRatings map[int]map[int]float64, workerCoun
 		go func() {
 			defer wg.Done()
 			local := newPartialAcc()
-			buf := make([]int, 0, 64)
 			for blk := range workCh {
-				buf = processUserBlock(local, blk, userRatings, buf)
+				processUserBlock(local, blk, userVecs)
 			}
 			partCh <- local
 		}()
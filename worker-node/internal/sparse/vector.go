@@ -0,0 +1,111 @@
+package sparse
+
+import (
+	"math"
+	"sort"
+)
+
+// Vector es un vector disperso de ratings con los IDs ordenados de menor a
+// mayor. IDs y Values son paralelos. Las normas y la media se calculan una
+// sola vez al construirlo, así comparar un objetivo contra miles de
+// candidatos no las recalcula en cada par.
+type Vector struct {
+	IDs    []int32
+	Values []float32
+
+	sum          float64
+	norm         float64 // ||v||
+	centeredNorm float64 // ||v − media(v)||
+}
+
+// FromMap construye el vector a partir de un mapa movieID -> rating.
+func FromMap(m map[int]float64) Vector {
+	ids := make([]int32, 0, len(m))
+	for id := range m {
+		ids = append(ids, int32(id))
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	values := make([]float32, len(ids))
+	for i, id := range ids {
+		values[i] = float32(m[int(id)])
+	}
+	return New(ids, values)
+}
+
+// New construye el vector a partir de IDs ya ordenados y sus valores.
+func New(ids []int32, values []float32) Vector {
+	v := Vector{IDs: ids, Values: values}
+	var sq float64
+	for _, x := range values {
+		f := float64(x)
+		v.sum += f
+		sq += f * f
+	}
+	v.norm = math.Sqrt(sq)
+	if n := len(values); n > 0 {
+		mean := v.sum / float64(n)
+		if c := sq - float64(n)*mean*mean; c > 0 {
+			v.centeredNorm = math.Sqrt(c)
+		}
+	}
+	return v
+}
+
+// FromRatings convierte la matriz userID -> movieID -> rating a vectores.
+func FromRatings(ratings map[int]map[int]float64) map[int]Vector {
+	out := make(map[int]Vector, len(ratings))
+	for id, m := range ratings {
+		out[id] = FromMap(m)
+	}
+	return out
+}
+
+func (v *Vector) Len() int { return len(v.IDs) }
+
+func (v *Vector) Norm() float64 { return v.norm }
+
+// CenteredNorm es la norma del vector centrado en su media.
+func (v *Vector) CenteredNorm() float64 { return v.centeredNorm }
+
+func (v *Vector) Mean() float64 {
+	if len(v.Values) == 0 {
+		return 0
+	}
+	return v.sum / float64(len(v.Values))
+}
+
+// CoStats son los acumulados sobre los IDs presentes en ambos vectores.
+type CoStats struct {
+	N          int     // IDs en común
+	Dot        float64 // Σ a·b
+	SumA, SumB float64 // Σ a, Σ b
+	SqA, SqB   float64 // Σ a², Σ b²
+}
+
+// Overlap recorre los dos vectores en paralelo (merge-join sobre los IDs
+// ordenados) y acumula las estadísticas de los IDs en común. No reserva
+// memoria.
+func Overlap(a, b *Vector) CoStats {
+	var co CoStats
+	i, j := 0, 0
+	for i < len(a.IDs) && j < len(b.IDs) {
+		switch {
+		case a.IDs[i] < b.IDs[j]:
+			i++
+		case a.IDs[i] > b.IDs[j]:
+			j++
+		default:
+			x, y := float64(a.Values[i]), float64(b.Values[j])
+			co.N++
+			co.Dot += x * y
+			co.SumA += x
+			co.SumB += y
+			co.SqA += x * x
+			co.SqB += y * y
+			i++
+			j++
+		}
+	}
+	return co
+}
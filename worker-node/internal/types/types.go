@@ -46,7 +46,7 @@ type Rec struct {
 
 // From benchmark
 type BenchRow struct {
-	Workers int
-	Millis  int64
-	Speedup float64
+	Workers int     `json:"workers"`
+	Millis  int64   `json:"ms"`
+	Speedup float64 `json:"speedup"`
 }